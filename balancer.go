@@ -0,0 +1,141 @@
+package lotus
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultHashReplicas is the number of points each instance has on the ring of a ConsistentHashBalancer
+const DefaultHashReplicas = 100
+
+// Balancer picks the instance of a service a request is sent to. Balancers are shared by copies of a ServiceClient,
+// so they must be safe for concurrent use
+type Balancer interface {
+	// Pick returns one of addresses. key is the ServiceRequest BalanceKey. done is called once the request finishes
+	Pick(addresses []string, key string) (address string, done func())
+}
+
+func noop() {}
+
+// randomPick is used by clients without Balancer
+func randomPick(addresses []string) string {
+	return addresses[rand.Intn(len(addresses))]
+}
+
+// RoundRobinBalancer sends requests to each instance in turn. The zero value is ready to use
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+func (b *RoundRobinBalancer) Pick(addresses []string, _ string) (string, func()) {
+	i := atomic.AddUint64(&b.next, 1) - 1
+	return addresses[i%uint64(len(addresses))], noop
+}
+
+// LeastOutstandingBalancer sends requests to the instance with the fewest requests in flight. Ties are broken in
+// turns. The zero value is ready to use
+type LeastOutstandingBalancer struct {
+	mu          sync.Mutex
+	next        int
+	outstanding map[string]int
+}
+
+func (b *LeastOutstandingBalancer) Pick(addresses []string, _ string) (string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.outstanding == nil {
+		b.outstanding = map[string]int{}
+	}
+
+	start := b.next % len(addresses)
+	b.next++
+	picked := addresses[start]
+	for i := 1; i < len(addresses); i++ {
+		address := addresses[(start+i)%len(addresses)]
+		if b.outstanding[address] < b.outstanding[picked] {
+			picked = address
+		}
+	}
+
+	b.outstanding[picked]++
+	var once sync.Once
+	return picked, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.outstanding[picked]--; b.outstanding[picked] <= 0 {
+				delete(b.outstanding, picked)
+			}
+		})
+	}
+}
+
+// ConsistentHashBalancer sends requests with the same BalanceKey to the same instance, moving only the keys of an
+// instance when it's added or removed. Requests without key are sent to each instance in turn. The zero value is
+// ready to use
+type ConsistentHashBalancer struct {
+	// Replicas is the number of points of each instance on the ring. Defaults to DefaultHashReplicas
+	Replicas int
+
+	mu         sync.Mutex
+	members    string
+	ring       []uint32
+	owners     map[uint32]string
+	roundRobin RoundRobinBalancer
+}
+
+func (b *ConsistentHashBalancer) Pick(addresses []string, key string) (string, func()) {
+	if key == "" {
+		return b.roundRobin.Pick(addresses, key)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.build(addresses)
+	h := hashKey(key)
+	i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i] >= h })
+	if i == len(b.ring) {
+		i = 0
+	}
+	return b.owners[b.ring[i]], noop
+}
+
+// build rebuilds the ring when the instances change
+func (b *ConsistentHashBalancer) build(addresses []string) {
+	sorted := append([]string(nil), addresses...)
+	sort.Strings(sorted)
+	members := strings.Join(sorted, ",")
+	if members == b.members {
+		return
+	}
+
+	replicas := b.Replicas
+	if replicas <= 0 {
+		replicas = DefaultHashReplicas
+	}
+	b.members = members
+	b.ring = make([]uint32, 0, len(sorted)*replicas)
+	b.owners = make(map[uint32]string, len(sorted)*replicas)
+	for _, address := range sorted {
+		for i := 0; i < replicas; i++ {
+			h := hashKey(address + "#" + strconv.Itoa(i))
+			if _, ok := b.owners[h]; ok {
+				continue
+			}
+			b.owners[h] = address
+			b.ring = append(b.ring, h)
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i] < b.ring[j] })
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,111 @@
+package lotus
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var balancedAddresses = []string{"127.0.0.1:10097", "127.0.0.1:10098", "127.0.0.1:10099"}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	balancer := &RoundRobinBalancer{}
+	for i := 0; i < 6; i++ {
+		address, done := balancer.Pick(balancedAddresses, "")
+		done()
+		assert.Equal(t, balancedAddresses[i%3], address, "Instances must be picked in turns")
+	}
+}
+
+func TestLeastOutstandingBalancer(t *testing.T) {
+	balancer := &LeastOutstandingBalancer{}
+	first, doneFirst := balancer.Pick(balancedAddresses, "")
+	second, _ := balancer.Pick(balancedAddresses, "")
+	third, _ := balancer.Pick(balancedAddresses, "")
+	assert.ElementsMatch(t, balancedAddresses, []string{first, second, third}, "Idle instances must be picked first")
+
+	doneFirst()
+	doneFirst()
+	address, _ := balancer.Pick(balancedAddresses, "")
+	assert.Equal(t, first, address, "The instance with the fewest requests in flight must be picked")
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	balancer := &ConsistentHashBalancer{}
+	picked := map[string]string{}
+	for i := 0; i < 100; i++ {
+		key := "user-" + strconv.Itoa(i)
+		picked[key], _ = balancer.Pick(balancedAddresses, key)
+		again, _ := balancer.Pick(balancedAddresses, key)
+		assert.Equal(t, picked[key], again, "The same key must be sent to the same instance")
+	}
+
+	remaining := balancedAddresses[:2]
+	used := map[string]bool{}
+	for key, address := range picked {
+		used[address] = true
+		moved, _ := balancer.Pick(remaining, key)
+		if address != balancedAddresses[2] {
+			assert.Equal(t, address, moved, "Keys of the remaining instances must not move")
+		}
+	}
+	assert.Len(t, used, 3, "Keys must be spread over every instance")
+
+	first, _ := balancer.Pick(remaining, "")
+	second, _ := balancer.Pick(remaining, "")
+	assert.NotEqual(t, first, second, "Requests without key must be sent in turns")
+}
+
+func TestHealthCheck(t *testing.T) {
+	first := startInstance(t, 10097)
+	defer first.Stop()
+	second := startInstance(t, 10098)
+	defer second.Stop()
+
+	healthCheck := &HealthCheck{Threshold: 1, Interval: time.Hour}
+	client := ServiceClient{
+		ServiceContract: &InstancesServiceContract,
+		Resolver:        StaticResolver{"127.0.0.1:10097", "127.0.0.1:10098", "127.0.0.1:10099"},
+		Balancer:        &RoundRobinBalancer{},
+		HealthCheck:     healthCheck,
+	}
+
+	failed := 0
+	called := map[string]int{}
+	for i := 0; i < 9; i++ {
+		var address string
+		if err := client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, &address); err != nil {
+			failed++
+			continue
+		}
+		called[address]++
+	}
+	assert.LessOrEqual(t, failed, 1, "A failing instance must be ejected")
+	assert.False(t, healthCheck.Healthy("127.0.0.1:10099"), "The stopped instance must be ejected")
+	assert.Len(t, called, 2, "Requests must be balanced over the healthy instances")
+
+	third := startInstance(t, 10099)
+	defer third.Stop()
+	healthCheck.Interval = 10 * time.Millisecond
+	client.Pool = &ClientPool{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.RunHealthCheck(ctx)
+	assert.Eventually(t, func() bool {
+		return healthCheck.Healthy("127.0.0.1:10099")
+	}, 5*time.Second, 10*time.Millisecond, "Probes must add a recovered instance back without requests")
+	assert.Empty(t, client.Pool.Status(), "Probes must not be counted by the client pool")
+
+	subscriber := Service{
+		ServiceContract: &SecureServiceContract,
+		Resolver:        client.Resolver,
+		ConfigureClient: func(client *ServiceClient) {
+			client.Balancer = &ConsistentHashBalancer{}
+		},
+	}
+	subscriber.SubscribeToService(InstancesServiceContract)
+	subscriber.startServiceClients()
+	assert.IsType(t, &ConsistentHashBalancer{}, subscriber.serviceClients[0].Balancer, "Subscribed clients must be configured")
+}
@@ -0,0 +1,259 @@
+package lotus
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit of a CircuitBreaker
+type CircuitState string
+
+const (
+	// CircuitClosed lets every request through while counting failures
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails every request fast until the cool-down elapses
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a few probe requests through to decide whether to close or open the circuit again
+	CircuitHalfOpen CircuitState = "half-open"
+
+	// DefaultCircuitFailureRate is the failure rate opening a circuit
+	DefaultCircuitFailureRate = 0.5
+	// DefaultCircuitMinRequests is the number of requests in a window before its failure rate is evaluated
+	DefaultCircuitMinRequests = 10
+	// DefaultCircuitWindow is the period requests and failures are counted over
+	DefaultCircuitWindow = 10 * time.Second
+	// DefaultCircuitCoolDown is the time a circuit stays open
+	DefaultCircuitCoolDown = 5 * time.Second
+	// DefaultCircuitHalfOpenRequests is the number of probe requests of a half-open circuit
+	DefaultCircuitHalfOpenRequests = 1
+)
+
+// ErrCircuitOpen matches the errors of requests failed fast by an open circuit, using errors.Is
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitOpenError is returned by ServiceClient calls rejected by an open circuit, without sending the request
+type CircuitOpenError struct {
+	// Service is the label of the called service
+	Service string
+	// Route is the label of the called route. It's only set for circuits kept per route
+	Route string
+	// RetryAfter is the remaining time before the circuit lets probe requests through
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	name := e.Service
+	if e.Route != "" {
+		name += " " + e.Route
+	}
+	return fmt.Sprintf("%s: %s, retry after %s", name, ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitStatus is the state of a circuit, as reported by ServiceStatus
+type CircuitStatus struct {
+	Service string
+	// Route is empty unless the CircuitBreaker keeps a circuit per route
+	Route string
+	State CircuitState
+	// Requests and Failures are counted over the current window
+	Requests int
+	Failures int
+}
+
+// CircuitBreaker stops calling services that keep failing. It keeps a circuit per service, or per route with
+// PerRoute. A closed circuit opens when the failure rate of a window reaches FailureRate, once MinRequests were sent.
+// An open circuit rejects requests with a *CircuitOpenError until CoolDown elapses and becomes half-open. A half-open
+// circuit lets HalfOpenRequests requests through, closing once all of them succeed and opening again on the first
+// failure. A request fails on a transport error or a 5xx status. The zero value is ready to use and can be shared by
+// the clients of different services
+type CircuitBreaker struct {
+	// FailureRate is the ratio of failed requests, between 0 and 1, that opens a circuit. Defaults to
+	// DefaultCircuitFailureRate
+	FailureRate float64
+	// MinRequests is the number of requests in a window before its failure rate is evaluated. Defaults to
+	// DefaultCircuitMinRequests
+	MinRequests int
+	// Window is the period requests and failures are counted over. Defaults to DefaultCircuitWindow
+	Window time.Duration
+	// CoolDown is the time a circuit stays open. Defaults to DefaultCircuitCoolDown
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of probe requests of a half-open circuit. Defaults to
+	// DefaultCircuitHalfOpenRequests
+	HalfOpenRequests int
+	// PerRoute keeps a circuit per route instead of one per service
+	PerRoute bool
+
+	mu       sync.Mutex
+	circuits map[circuitKey]*circuit
+}
+
+type circuitKey struct {
+	service string
+	route   string
+}
+
+type circuit struct {
+	state CircuitState
+	since time.Time
+	// generation changes with the state so outcomes of requests sent in a previous state are ignored
+	generation int
+	requests   int
+	failures   int
+	probes     int
+}
+
+// transition moves the circuit to state, resetting its counters
+func (c *circuit) transition(state CircuitState) {
+	c.state, c.since = state, time.Now()
+	c.generation++
+	c.requests, c.failures, c.probes = 0, 0, 0
+}
+
+// allow returns a function recording the outcome of the request, or a *CircuitOpenError when the circuit rejects it.
+// Requests that weren't sent or that the caller gave up on, recorded with a zero status and no error, release their
+// probe without being counted
+func (cb *CircuitBreaker) allow(service, route string) (func(err error, status int), error) {
+	if cb == nil {
+		return func(error, int) {}, nil
+	}
+	key := circuitKey{service: service}
+	if cb.PerRoute {
+		key.route = route
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.circuits == nil {
+		cb.circuits = map[circuitKey]*circuit{}
+	}
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{}
+		c.transition(CircuitClosed)
+		cb.circuits[key] = c
+	}
+
+	now := time.Now()
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.since) >= cb.window() {
+			c.since, c.requests, c.failures = now, 0, 0
+		}
+	case CircuitOpen:
+		if wait := cb.coolDown() - now.Sub(c.since); wait > 0 {
+			return nil, &CircuitOpenError{Service: key.service, Route: key.route, RetryAfter: wait}
+		}
+		c.transition(CircuitHalfOpen)
+	}
+	if c.state == CircuitHalfOpen {
+		if c.probes >= cb.halfOpenRequests() {
+			return nil, &CircuitOpenError{Service: key.service, Route: key.route}
+		}
+		c.probes++
+	}
+
+	generation := c.generation
+	var once sync.Once
+	return func(err error, status int) {
+		once.Do(func() {
+			cb.mu.Lock()
+			defer cb.mu.Unlock()
+			if c.generation == generation {
+				cb.record(c, err, status)
+			}
+		})
+	}, nil
+}
+
+// record updates the circuit with the outcome of a request let through in its current state
+func (cb *CircuitBreaker) record(c *circuit, err error, status int) {
+	if c.state == CircuitHalfOpen {
+		c.probes--
+	}
+	if err == nil && status == 0 {
+		return
+	}
+	c.requests++
+	if !healthyResponse(err, status) {
+		c.failures++
+	}
+
+	switch {
+	case c.state == CircuitHalfOpen && c.failures > 0:
+		c.transition(CircuitOpen)
+	case c.state == CircuitHalfOpen && c.requests >= cb.halfOpenRequests():
+		c.transition(CircuitClosed)
+	case c.state == CircuitClosed && c.requests >= cb.minRequests() &&
+		float64(c.failures)/float64(c.requests) >= cb.failureRate():
+		c.transition(CircuitOpen)
+	}
+}
+
+// Status returns the state of the circuits, sorted by service and route
+func (cb *CircuitBreaker) Status() []CircuitStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	status := make([]CircuitStatus, 0, len(cb.circuits))
+	for key, c := range cb.circuits {
+		state := c.state
+		if state == CircuitOpen && time.Since(c.since) >= cb.coolDown() {
+			state = CircuitHalfOpen
+		}
+		status = append(status, CircuitStatus{
+			Service:  key.service,
+			Route:    key.route,
+			State:    state,
+			Requests: c.requests,
+			Failures: c.failures,
+		})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].Service != status[j].Service {
+			return status[i].Service < status[j].Service
+		}
+		return status[i].Route < status[j].Route
+	})
+	return status
+}
+
+func (cb *CircuitBreaker) failureRate() float64 {
+	if cb.FailureRate <= 0 {
+		return DefaultCircuitFailureRate
+	}
+	return cb.FailureRate
+}
+
+func (cb *CircuitBreaker) minRequests() int {
+	if cb.MinRequests <= 0 {
+		return DefaultCircuitMinRequests
+	}
+	return cb.MinRequests
+}
+
+func (cb *CircuitBreaker) window() time.Duration {
+	if cb.Window <= 0 {
+		return DefaultCircuitWindow
+	}
+	return cb.Window
+}
+
+func (cb *CircuitBreaker) coolDown() time.Duration {
+	if cb.CoolDown <= 0 {
+		return DefaultCircuitCoolDown
+	}
+	return cb.CoolDown
+}
+
+func (cb *CircuitBreaker) halfOpenRequests() int {
+	if cb.HalfOpenRequests <= 0 {
+		return DefaultCircuitHalfOpenRequests
+	}
+	return cb.HalfOpenRequests
+}
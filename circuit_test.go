@@ -0,0 +1,88 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := &CircuitBreaker{MinRequests: 4, FailureRate: 0.5, CoolDown: 20 * time.Millisecond, HalfOpenRequests: 2}
+	send := func(status int) error {
+		record, err := breaker.allow("Users", "GetUser")
+		if err == nil {
+			record(nil, status)
+		}
+		return err
+	}
+
+	assert.Nil(t, send(fasthttp.StatusOK))
+	assert.Nil(t, send(fasthttp.StatusNotFound), "Client errors must not count as failures")
+	assert.Nil(t, send(fasthttp.StatusServiceUnavailable))
+	assert.Equal(t, CircuitClosed, breaker.Status()[0].State, "The circuit must stay closed below the failure rate")
+	assert.Nil(t, send(fasthttp.StatusBadGateway))
+	assert.Equal(t, CircuitOpen, breaker.Status()[0].State, "The circuit must open once the failure rate is reached")
+
+	err := send(fasthttp.StatusOK)
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr), "An open circuit must reject requests")
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "Rejected requests must match ErrCircuitOpen")
+	assert.Equal(t, "Users", openErr.Service)
+	assert.Empty(t, openErr.Route, "Circuits must be kept per service by default")
+	assert.Greater(t, int64(openErr.RetryAfter), int64(0))
+
+	time.Sleep(20 * time.Millisecond)
+	first, err := breaker.allow("Users", "GetUser")
+	assert.Nil(t, err, "The circuit must let probes through after the cool-down")
+	second, _ := breaker.allow("Users", "GetUser")
+	_, err = breaker.allow("Users", "GetUser")
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "A half-open circuit must only let HalfOpenRequests through")
+	first(nil, fasthttp.StatusOK)
+	assert.Equal(t, CircuitHalfOpen, breaker.Status()[0].State)
+	second(nil, fasthttp.StatusOK)
+	assert.Equal(t, CircuitClosed, breaker.Status()[0].State, "Successful probes must close the circuit")
+
+	for i := 0; i < 4; i++ {
+		send(fasthttp.StatusInternalServerError)
+	}
+	time.Sleep(20 * time.Millisecond)
+	probe, _ := breaker.allow("Users", "GetUser")
+	probe(errors.New("connection refused"), 0)
+	assert.Equal(t, CircuitOpen, breaker.Status()[0].State, "A failed probe must open the circuit again")
+
+	perRoute := &CircuitBreaker{PerRoute: true, MinRequests: 1}
+	record, _ := perRoute.allow("Users", "GetUser")
+	record(nil, fasthttp.StatusInternalServerError)
+	_, err = perRoute.allow("Users", "GetUser")
+	assert.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "GetUser", openErr.Route)
+	_, err = perRoute.allow("Users", "ListUsers")
+	assert.Nil(t, err, "Circuits kept per route must not affect other routes")
+}
+
+func TestServiceCircuitBreaker(t *testing.T) {
+	contract := InstancesServiceContract
+	contract.Port = 10102
+	subscriber := Service{
+		ServiceContract: &SecureServiceContract,
+		CircuitBreaker:  &CircuitBreaker{MinRequests: 2, CoolDown: time.Minute},
+	}
+	subscriber.SubscribeToService(contract)
+	subscriber.startServiceClients()
+	client := subscriber.serviceClients[0]
+
+	for i := 0; i < 2; i++ {
+		err := client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, nil)
+		assert.False(t, errors.Is(err, ErrCircuitOpen), "Requests must be sent while the circuit is closed")
+	}
+	start := time.Now()
+	err := client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "Calls to a failing service must fail fast")
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+
+	status := subscriber.Status()
+	assert.Equal(t, []CircuitStatus{{Service: "Instances", State: CircuitOpen}}, status.Circuits, "Circuits must be reported by the service status")
+}
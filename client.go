@@ -1,31 +1,251 @@
 package lotus
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/valyala/fasthttp"
+	"time"
 )
 
 type ServiceClient struct {
 	*ServiceContract
+	// TLSConfig is the configuration used to call HTTPS services, e.g. a CA pool and client certificates for mutual
+	// TLS. The default configuration is used when nil
+	TLSConfig *tls.Config
+	// Resolver finds the instances of the service. The contract Host and Port are used when nil
+	Resolver Resolver
+	// Balancer picks the resolved instance each request is sent to. Instances are picked at random when nil
+	Balancer Balancer
+	// HealthCheck ejects failing instances from the resolved ones. Every instance is used when nil
+	HealthCheck *HealthCheck
+	// Retry is the RetryPolicy of routes without one. Requests are sent once when nil
+	Retry *RetryPolicy
+	// CircuitBreaker fails requests fast while the service keeps failing. Requests are always sent when nil
+	CircuitBreaker *CircuitBreaker
+	// Timeout bounds calls to routes without Timeout. Calls are only bounded by their context when zero
+	Timeout time.Duration
+	// Pool keeps the connections to each instance of the service. The fasthttp default client is used when nil
+	Pool *ClientPool
+}
+
+// ResponseError is returned by Call when the service answers with a non 2xx status code
+type ResponseError struct {
+	// Route is the label of the called route
+	Route string
+	// StatusCode is the status code of the response
+	StatusCode int
+	// ContentType is the content type of the response body
+	ContentType string
+	// Body is a copy of the response body
+	Body []byte
+	// Err is the *Error decoded from the body. It's nil when the body doesn't hold one
+	Err *Error
+}
+
+// newResponseError returns the ResponseError of a response of the route
+func newResponseError(route string, resp *fasthttp.Response) *ResponseError {
+	return &ResponseError{
+		Route:       route,
+		StatusCode:  resp.StatusCode(),
+		ContentType: string(resp.Header.ContentType()),
+		Body:        append([]byte(nil), resp.Body()...),
+		Err:         decodeError(resp),
+	}
+}
+
+func (e *ResponseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("route %s responded with status %d: %s", e.Route, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("route %s responded with status %d: %s", e.Route, e.StatusCode, e.Body)
+}
+
+// Unwrap returns the *Error answered by the service, so it can be matched with errors.Is and errors.As
+func (e *ResponseError) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
 }
 
 // Sends a request and returns a response and an error. The response must be released
 func (sc *ServiceClient) SendRequest(routeContract RouteContract, payload ServiceRequest) (*fasthttp.Response, error) {
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
+	return sc.SendRequestContext(context.Background(), routeContract, payload)
+}
 
+// SendRequestContext sends a request bounded by the ctx deadline and returns a response and an error. Pass the
+// handler Context to propagate the remaining budget of the request being served. The response must be released
+func (sc *ServiceClient) SendRequestContext(ctx context.Context, routeContract RouteContract, payload ServiceRequest) (*fasthttp.Response, error) {
 	resp := fasthttp.AcquireResponse()
+	err := sc.send(ctx, routeContract, payload, resp)
+	return resp, err
+}
+
+// Call sends a request to the route and decodes the response body into out, which must be a pointer. The body is
+// decoded according to the response Content-Type. Responses with a non 2xx status return a *ResponseError, which
+// unwraps to the *Error answered by the service, if any. The request is bounded by the ctx deadline, if any
+func (sc *ServiceClient) Call(ctx context.Context, routeContract RouteContract, payload ServiceRequest, out interface{}) error {
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
 
-	url, err := sc.RouteUrl(routeContract.Label)
+	err := sc.send(ctx, routeContract, payload, resp)
 	if err != nil {
-		return resp, err
+		return err
+	}
+
+	status := resp.StatusCode()
+	if status < fasthttp.StatusOK || status >= fasthttp.StatusMultipleChoices {
+		return newResponseError(routeContract.Label, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return decodeResponse(mediaType(resp.Header.ContentType()), resp.Body(), out)
+}
+
+// send sends the request to an instance of the service, retrying it according to the route RetryPolicy, or the
+// client one when the route has none. Each attempt picks an instance and goes through the CircuitBreaker. Attempts
+// share the deadline of the call, whose remaining budget is sent on the TimeoutHeader. Calls past their deadline or
+// canceled return the ctx error. resp holds the response of the last attempt
+func (sc *ServiceClient) send(ctx context.Context, routeContract RouteContract, payload ServiceRequest, resp *fasthttp.Response) error {
+	caller := ctx
+	ctx, cancel := sc.withTimeout(ctx, routeContract, payload)
+	defer cancel()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	policy := routeContract.Retry
+	if policy == nil {
+		policy = sc.Retry
 	}
+	retry := false
+	deadline, _ := ctx.Deadline()
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := sc.CircuitBreaker.allow(sc.Label, routeContract.Label)
+		if err != nil {
+			return err
+		}
+		address, done, err := sc.pick(ctx, payload.BalanceKey)
+		if err != nil {
+			record(nil, 0)
+			return err
+		}
+
+		if attempt == 1 {
+			err = sc.prepareRequest(req, address, routeContract, payload)
+			if err != nil {
+				done(nil, 0)
+				record(nil, 0)
+				return err
+			}
+			retry = policy.allows(string(req.Header.Method()), payload.IdempotencyKey)
+		} else {
+			req.URI().SetHost(address)
+			resp.Reset()
+		}
 
+		setTimeoutHeader(req, deadline)
+		err = sc.do(req, resp, deadline)
+		if callerGaveUp(caller, payload, err) {
+			done(nil, 0)
+			record(nil, 0)
+		} else {
+			done(err, resp.StatusCode())
+			record(err, resp.StatusCode())
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == fasthttp.ErrTimeout && !deadline.IsZero() && !time.Now().Before(deadline) {
+			// the deadline of the request may expire before the one of ctx is reported
+			return context.DeadlineExceeded
+		}
+		if !retry || attempt >= policy.MaxAttempts || !policy.retryable(err, resp.StatusCode()) {
+			return err
+		}
+		if policy.wait(ctx, attempt) != nil {
+			return err
+		}
+	}
+}
+
+// prepareRequest writes the request to the route on the instance at address
+func (sc *ServiceClient) prepareRequest(req *fasthttp.Request, address string, routeContract RouteContract, payload ServiceRequest) error {
+	url, err := sc.routeUrlAt(address, routeContract.Label)
+	if err != nil {
+		return err
+	}
 	req.SetRequestURI(url)
 	err = routeContract.prepareRequest(req, payload)
 	if err != nil {
-		return resp, err
+		return err
 	}
+	if payload.IdempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, payload.IdempotencyKey)
+	}
+	return nil
+}
 
-	err = fasthttp.Do(req, resp)
-	return resp, err
+// pick returns the address of the instance called and a function reporting the outcome of the request to the
+// Balancer and HealthCheck. It's the contract address when there's no Resolver
+func (sc *ServiceClient) pick(ctx context.Context, key string) (string, func(err error, status int), error) {
+	if sc.Resolver == nil {
+		return sc.address(), func(error, int) {}, nil
+	}
+	addresses, err := sc.Resolver.Resolve(ctx, sc.ServiceContract)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(addresses) == 0 {
+		return "", nil, fmt.Errorf("%s: %w", sc.Label, ErrNoInstances)
+	}
+
+	if sc.HealthCheck != nil {
+		addresses = sc.HealthCheck.available(addresses)
+	}
+	if sc.Balancer == nil {
+		address := randomPick(addresses)
+		return address, sc.reporter(address, noop), nil
+	}
+	address, done := sc.Balancer.Pick(addresses, key)
+	return address, sc.reporter(address, done), nil
+}
+
+// reporter returns a function calling done and reporting the outcome of a request to the HealthCheck. Requests that
+// weren't sent or that the caller gave up on, reported with a zero status and no error, aren't reported
+func (sc *ServiceClient) reporter(address string, done func()) func(err error, status int) {
+	return func(err error, status int) {
+		done()
+		if sc.HealthCheck != nil && (err != nil || status != 0) {
+			sc.HealthCheck.report(address, healthyResponse(err, status))
+		}
+	}
+}
+
+// do performs the request using the client Pool and TLS configuration, if any. A zero deadline means no deadline
+func (sc *ServiceClient) do(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error {
+	if sc.Pool != nil {
+		return sc.Pool.do(req, resp, deadline, sc.TLSConfig)
+	}
+	return sc.doUnpooled(req, resp, deadline)
+}
+
+// doUnpooled sends the request with the fasthttp default client, or a TLS one when TLSConfig is set
+func (sc *ServiceClient) doUnpooled(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error {
+	if sc.TLSConfig != nil {
+		client := tlsClient(sc.TLSConfig)
+		if deadline.IsZero() {
+			return client.Do(req, resp)
+		}
+		return client.DoDeadline(req, resp, deadline)
+	}
+	if deadline.IsZero() {
+		return fasthttp.Do(req, resp)
+	}
+	return fasthttp.DoDeadline(req, resp, deadline)
 }
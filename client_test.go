@@ -1,11 +1,12 @@
 package lotus
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
 	"testing"
-	"time"
 )
 
 type EchoPayload struct {
@@ -32,7 +33,7 @@ var (
 		},
 	}
 	echoServiceClient = ServiceClient{
-		&serviceClientContract,
+		ServiceContract: &serviceClientContract,
 	}
 	defaultPayload = ServiceRequest{
 		Body: EchoPayload{
@@ -54,11 +55,9 @@ func TestSendRequest(t *testing.T) {
 	service.SetupRoute("SimpleEcho", echo, nil, nil)
 	service.SetupRoute("PostEcho", echoPayload, nil, nil)
 
-	go service.Start()
+	startService(t, &service)
 	defer service.Stop()
 
-	time.Sleep(2 * time.Second)
-
 	resp, err := echoServiceClient.SendRequest(postEchoRouteContract, defaultPayload)
 	defer fasthttp.ReleaseResponse(resp)
 
@@ -70,3 +69,58 @@ func TestSendRequest(t *testing.T) {
 	assert.Equal(t, fmt.Sprint(defaultPayload.Body), string(body), "Result payload must be equal sent payload")
 
 }
+
+var (
+	typedEchoRouteContract = RouteContract{
+		Label:  "TypedEcho",
+		Path:   "/typed",
+		Method: fasthttp.MethodPost,
+		Data:   EchoPayload{},
+	}
+	failingRouteContract = RouteContract{
+		Label: "Failing",
+		Path:  "/failing",
+	}
+	callServiceContract = ServiceContract{
+		Label: "CallService",
+		Port:  10082,
+		RoutesContracts: []RouteContract{
+			typedEchoRouteContract,
+			failingRouteContract,
+		},
+	}
+)
+
+func TestCall(t *testing.T) {
+	service := Service{ServiceContract: &callServiceContract}
+	echoHandler := TypedRoute[EchoPayload, TypedEchoResponse](func(ctx *Context, req EchoPayload) (TypedEchoResponse, error) {
+		return TypedEchoResponse{Echo: req.Foo + req.Bar}, nil
+	})
+	service.SetupRoute("TypedEcho", echoHandler.Handler(), nil, nil)
+	service.SetupRoute("Failing", func(ctx *Context) {
+		ctx.SetStatusCode(fasthttp.StatusTeapot)
+		ctx.WriteString("failed")
+	}, nil, nil)
+
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &callServiceContract}
+
+	var out TypedEchoResponse
+	err := client.Call(context.Background(), typedEchoRouteContract, defaultPayload, &out)
+	assert.Nil(t, err, "Calling a route must not return an error")
+	assert.Equal(t, "foobar", out.Echo, "Call must decode the response into the typed result")
+
+	var body string
+	err = client.Call(context.Background(), failingRouteContract, ServiceRequest{}, &body)
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr), "A non 2xx response must return a ResponseError")
+	assert.Equal(t, fasthttp.StatusTeapot, respErr.StatusCode, "ResponseError must hold the response status")
+	assert.Equal(t, "failed", string(respErr.Body), "ResponseError must hold the response body")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.Call(ctx, typedEchoRouteContract, defaultPayload, &out)
+	assert.Equal(t, context.Canceled, err, "Calling with a canceled context must fail")
+}
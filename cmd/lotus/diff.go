@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/brunvieira/lotus"
+	"github.com/valyala/fasthttp"
+	"os"
+	"strings"
+)
+
+func diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	additive := flags.Bool("additive", true, "list additive changes")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+
+	previous, err := loadSnapshot(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	next, err := loadSnapshot(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	result := lotus.DiffContracts(previous, next)
+	for _, change := range result.Changes {
+		if change.Kind == lotus.AdditiveChange && !*additive {
+			continue
+		}
+		fmt.Println(change)
+	}
+	if !result.IsCompatible() {
+		fmt.Fprintf(os.Stderr, "breaking changes found: %d\n", len(result.Breaking()))
+		os.Exit(1)
+	}
+	return nil
+}
+
+// loadSnapshot reads a contract snapshot or a contract file, or fetches the contract endpoint of a running service
+func loadSnapshot(source string) (*lotus.ContractDescription, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		status, body, err := fasthttp.Get(nil, source)
+		if err != nil {
+			return nil, err
+		}
+		if status != fasthttp.StatusOK {
+			return nil, fmt.Errorf("%s responded with status %d", source, status)
+		}
+		data = body
+	} else {
+		body, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		data = body
+	}
+
+	desc, err := lotus.ParseContractDescription(data)
+	if err == nil {
+		return desc, nil
+	}
+	contract, contractErr := lotus.ParseContract(data)
+	if contractErr != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return contract.Describe(), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"github.com/brunvieira/lotus"
+	"os"
+	"path/filepath"
+)
+
+func gen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	pkg := flags.String("pkg", "", "package name of the generated code. Defaults to the output directory name")
+	out := flags.String("o", "", "output file. Defaults to the standard output")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	contract, err := lotus.LoadContract(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	name := *pkg
+	if name == "" {
+		name = "contract"
+		if *out != "" {
+			if abs, err := filepath.Abs(filepath.Dir(*out)); err == nil {
+				name = filepath.Base(abs)
+			}
+		}
+	}
+
+	src, err := lotus.GenerateGo(contract, name)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0644)
+}
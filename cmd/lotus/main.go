@@ -0,0 +1,48 @@
+// Command lotus provides tooling around lotus contracts.
+//
+// Usage:
+//
+//	lotus diff [-additive=false] <previous> <next>
+//	lotus gen [-pkg name] [-o file] <contract>
+//
+// diff compares two contract snapshots and lists breaking and additive changes. Snapshots are JSON files holding a
+// lotus.ContractDescription or lotus.ServiceDescription, YAML or JSON contract files, or the URL of a service
+// exposing its contract. It exits with status 1 when breaking changes are found, so it can gate releases.
+//
+// gen generates Go code from a YAML or JSON contract file: the routes Data types, the contract variables, a typed
+// client and a server interface for every service
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lotus diff [-additive=false] <previous> <next>")
+	fmt.Fprintln(os.Stderr, "       lotus gen [-pkg name] [-o file] <contract>")
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	var err error
+	switch flag.Arg(0) {
+	case "diff":
+		err = diff(flag.Args()[1:])
+	case "gen":
+		err = gen(flag.Args()[1:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lotus:", err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,656 @@
+package lotus
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// GenerateGo generates the Go source of package pkg for the contract. For every service it declares the Data types
+// of its routes, RouteContract and ServiceContract variables, a typed client wrapping ServiceClient and a server
+// interface with a function setting up its methods as the service routes
+func GenerateGo(contract *Contract, pkg string) ([]byte, error) {
+	g := &generator{
+		imports:  map[string]bool{},
+		types:    map[string]string{},
+		routeVar: map[*RouteContract]string{},
+	}
+	g.nameRoutes(contract)
+
+	var body bytes.Buffer
+	for i := range contract.Services {
+		if err := g.service(&body, &contract.Services[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by lotus gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	src.WriteString("import (\n")
+	for _, path := range imports {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	src.WriteString(")\n\n")
+	for _, name := range g.typeOrder {
+		src.WriteString(g.types[name])
+	}
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+type generator struct {
+	imports map[string]bool
+	// types holds the declaration of each generated type
+	types     map[string]string
+	typeOrder []string
+	// routeVar holds the name of the RouteContract variable of each route
+	routeVar map[*RouteContract]string
+}
+
+// nameRoutes names the RouteContract variables, prefixing them with the service name when labels are not unique
+func (g *generator) nameRoutes(contract *Contract) {
+	count := map[string]int{}
+	for _, sc := range contract.Services {
+		for _, route := range sc.RoutesContracts {
+			count[goName(route.Label)]++
+		}
+	}
+	for i := range contract.Services {
+		sc := &contract.Services[i]
+		for j := range sc.RoutesContracts {
+			route := &sc.RoutesContracts[j]
+			name := goName(route.Label)
+			if count[name] > 1 {
+				name = goName(sc.Label) + name
+			}
+			g.routeVar[route] = name + "RouteContract"
+		}
+	}
+}
+
+// generatedRoute holds what's generated for each route of a service
+type generatedRoute struct {
+	contract *RouteContract
+	name     string
+	variable string
+	// dataType is the Go type of the route Data, empty for routes without Data
+	dataType string
+	// params are the route params not bound to Data fields
+	params []string
+}
+
+func (g *generator) service(w *bytes.Buffer, sc *ServiceContract) error {
+	name := goName(sc.Label)
+	var routes []generatedRoute
+	for i := range sc.RoutesContracts {
+		route := &sc.RoutesContracts[i]
+		if err := route.validateMethod(); err != nil {
+			return err
+		}
+		schema, definitions := route.schema()
+		names := make([]string, 0, len(definitions))
+		for defName := range definitions {
+			names = append(names, defName)
+		}
+		sort.Strings(names)
+		for _, defName := range names {
+			def := *definitions[defName]
+			def.Title = defName
+			g.goType(&def, defName)
+		}
+
+		generated := generatedRoute{
+			contract: route,
+			name:     goName(route.Label),
+			variable: g.routeVar[route],
+			params:   route.routeParams(),
+		}
+		if schema != nil {
+			generated.dataType = g.goType(schema, generated.name+"Data")
+			generated.params = unboundParams(generated.params, schema)
+		}
+		routes = append(routes, generated)
+		g.routeContract(w, generated)
+	}
+
+	g.serviceContract(w, sc, name, routes)
+	g.client(w, sc, name, routes)
+	g.server(w, sc, name, routes)
+	return nil
+}
+
+func (g *generator) routeContract(w *bytes.Buffer, route generatedRoute) {
+	contract := route.contract
+	writeDoc(w, route.variable, "is the contract of the "+contract.Label+" route", contract.Description)
+	fmt.Fprintf(w, "var %s = lotus.RouteContract{\n", route.variable)
+	fmt.Fprintf(w, "Label: %q,\n", contract.Label)
+	if contract.Description != "" {
+		fmt.Fprintf(w, "Description: %q,\n", contract.Description)
+	}
+	fmt.Fprintf(w, "Method: %s,\n", methodConstant(contract.method()))
+	fmt.Fprintf(w, "Path: %q,\n", contract.Path)
+	config := contract.DataHandlerConfig
+	if config != (DataHandlerConfig{}) {
+		w.WriteString("DataHandlerConfig: lotus.DataHandlerConfig{\n")
+		if config.BodyType != "" {
+			fmt.Fprintf(w, "BodyType: %s,\n", dataTypeConstant(config.BodyType))
+		}
+		if config.UserValueKey != "" {
+			fmt.Fprintf(w, "UserValueKey: %q,\n", config.UserValueKey)
+		}
+		if config.MaxBodySize != 0 {
+			fmt.Fprintf(w, "MaxBodySize: %d,\n", config.MaxBodySize)
+		}
+		if config.MaxMultipartMemory != 0 {
+			fmt.Fprintf(w, "MaxMultipartMemory: %d,\n", config.MaxMultipartMemory)
+		}
+		w.WriteString("},\n")
+	}
+	if retry := contract.Retry; retry != nil {
+		w.WriteString("Retry: &lotus.RetryPolicy{\n")
+		if retry.MaxAttempts != 0 {
+			fmt.Fprintf(w, "MaxAttempts: %d,\n", retry.MaxAttempts)
+		}
+		if retry.InitialBackoff != 0 {
+			fmt.Fprintf(w, "InitialBackoff: %s,\n", g.durationLiteral(retry.InitialBackoff))
+		}
+		if retry.MaxBackoff != 0 {
+			fmt.Fprintf(w, "MaxBackoff: %s,\n", g.durationLiteral(retry.MaxBackoff))
+		}
+		if retry.Multiplier != 0 {
+			fmt.Fprintf(w, "Multiplier: %v,\n", retry.Multiplier)
+		}
+		if retry.RetryableStatuses != nil {
+			fmt.Fprintf(w, "RetryableStatuses: %#v,\n", retry.RetryableStatuses)
+		}
+		if retry.IgnoreNetworkErrors {
+			w.WriteString("IgnoreNetworkErrors: true,\n")
+		}
+		if retry.RetryNonIdempotent {
+			w.WriteString("RetryNonIdempotent: true,\n")
+		}
+		w.WriteString("},\n")
+	}
+	if contract.Timeout != 0 {
+		fmt.Fprintf(w, "Timeout: %s,\n", g.durationLiteral(contract.Timeout))
+	}
+	if route.dataType != "" {
+		fmt.Fprintf(w, "Data: %s,\n", g.zeroValue(route.dataType))
+	}
+	w.WriteString("}\n\n")
+	g.imports["github.com/brunvieira/lotus"] = true
+}
+
+// durationLiteral returns d as a Go expression using the largest time unit dividing it
+func (g *generator) durationLiteral(d time.Duration) string {
+	g.imports["time"] = true
+	for _, unit := range []struct {
+		name  string
+		value time.Duration
+	}{{"Hour", time.Hour}, {"Minute", time.Minute}, {"Second", time.Second}, {"Millisecond", time.Millisecond}} {
+		if d%unit.value == 0 {
+			return fmt.Sprintf("%d * time.%s", d/unit.value, unit.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", int64(d))
+}
+
+func (g *generator) serviceContract(w *bytes.Buffer, sc *ServiceContract, name string, routes []generatedRoute) {
+	writeDoc(w, name+"Contract", "is the contract of the "+sc.Label+" service", sc.Description)
+	fmt.Fprintf(w, "var %sContract = lotus.ServiceContract{\n", name)
+	fmt.Fprintf(w, "Label: %q,\n", sc.Label)
+	if sc.Description != "" {
+		fmt.Fprintf(w, "Description: %q,\n", sc.Description)
+	}
+	if sc.Protocol != "" {
+		fmt.Fprintf(w, "Protocol: %s,\n", protocolConstant(sc.Protocol))
+	}
+	if sc.Host != "" {
+		fmt.Fprintf(w, "Host: %q,\n", sc.Host)
+	}
+	if sc.Namespace != "" {
+		fmt.Fprintf(w, "Namespace: %q,\n", sc.Namespace)
+	}
+	if sc.Port != 0 {
+		fmt.Fprintf(w, "Port: %d,\n", sc.Port)
+	}
+	if sc.Version != "" {
+		fmt.Fprintf(w, "Version: %q,\n", sc.Version)
+	}
+	w.WriteString("RoutesContracts: []lotus.RouteContract{\n")
+	for _, route := range routes {
+		fmt.Fprintf(w, "%s,\n", route.variable)
+	}
+	w.WriteString("},\n")
+	if len(sc.Environments) > 0 {
+		envs := make([]string, 0, len(sc.Environments))
+		for env := range sc.Environments {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+		w.WriteString("Environments: map[string]lotus.ServiceOverride{\n")
+		for _, env := range envs {
+			override := sc.Environments[env]
+			fmt.Fprintf(w, "%q: {", env)
+			var fields []string
+			if override.Protocol != "" {
+				fields = append(fields, "Protocol: "+protocolConstant(override.Protocol))
+			}
+			if override.Host != "" {
+				fields = append(fields, "Host: "+strconv.Quote(override.Host))
+			}
+			if override.Port != 0 {
+				fields = append(fields, "Port: "+strconv.Itoa(override.Port))
+			}
+			w.WriteString(strings.Join(fields, ", "))
+			w.WriteString("},\n")
+		}
+		w.WriteString("},\n")
+	}
+	if options := sc.ClientOptions; options != nil {
+		w.WriteString("ClientOptions: &lotus.ClientOptions{\n")
+		for _, field := range []struct {
+			name  string
+			value int
+		}{{"MaxConns", options.MaxConns}, {"ReadBufferSize", options.ReadBufferSize}, {"WriteBufferSize", options.WriteBufferSize}} {
+			if field.value != 0 {
+				fmt.Fprintf(w, "%s: %d,\n", field.name, field.value)
+			}
+		}
+		for _, field := range []struct {
+			name  string
+			value time.Duration
+		}{
+			{"MaxConnWaitTimeout", options.MaxConnWaitTimeout},
+			{"MaxIdleConnDuration", options.MaxIdleConnDuration},
+			{"MaxConnDuration", options.MaxConnDuration},
+			{"DialTimeout", options.DialTimeout},
+			{"ReadTimeout", options.ReadTimeout},
+			{"WriteTimeout", options.WriteTimeout},
+		} {
+			if field.value != 0 {
+				fmt.Fprintf(w, "%s: %s,\n", field.name, g.durationLiteral(field.value))
+			}
+		}
+		if options.DisableKeepAlive {
+			w.WriteString("DisableKeepAlive: true,\n")
+		}
+		w.WriteString("},\n")
+	}
+	w.WriteString("}\n\n")
+}
+
+func (g *generator) client(w *bytes.Buffer, sc *ServiceContract, name string, routes []generatedRoute) {
+	g.imports["context"] = true
+	fmt.Fprintf(w, "// %sClient is a typed client of the %s service, e.g.\n", name, sc.Label)
+	fmt.Fprintf(w, "//\n//\tclient := %sClient{lotus.ServiceClient{ServiceContract: &%sContract}}\n", name, name)
+	fmt.Fprintf(w, "type %sClient struct {\nlotus.ServiceClient\n}\n\n", name)
+
+	fmt.Fprintf(w, "// %sClientFromContext returns the client of the %s service subscribed by the service handling ctx\n", name, sc.Label)
+	fmt.Fprintf(w, "func %sClientFromContext(ctx *lotus.Context) (*%sClient, bool) {\n", name, name)
+	w.WriteString("for _, client := range ctx.ServiceClients {\n")
+	fmt.Fprintf(w, "if client.ServiceContract != nil && client.Label == %q {\n", sc.Label)
+	fmt.Fprintf(w, "return &%sClient{client}, true\n}\n}\nreturn nil, false\n}\n\n", name)
+
+	for _, route := range routes {
+		args := []string{"ctx context.Context"}
+		if route.dataType != "" {
+			args = append(args, "data "+route.dataType)
+		}
+		params := paramNames(route.params)
+		for _, param := range params {
+			args = append(args, param+" string")
+		}
+		args = append(args, "out interface{}")
+
+		fmt.Fprintf(w, "// %s calls the %s route, decoding the response body into out\n", route.name, route.contract.Label)
+		fmt.Fprintf(w, "func (c *%sClient) %s(%s) error {\n", name, route.name, strings.Join(args, ", "))
+		var request []string
+		if len(params) > 0 {
+			values := make([]string, len(params))
+			for i, param := range params {
+				values[i] = fmt.Sprintf("%q: %s", route.params[i], param)
+			}
+			request = append(request, "RouteParams: map[string]string{"+strings.Join(values, ", ")+"}")
+		}
+		if route.dataType != "" {
+			request = append(request, "Body: data")
+		}
+		fmt.Fprintf(w, "return c.Call(ctx, %s, lotus.ServiceRequest{%s}, out)\n}\n\n", route.variable, strings.Join(request, ", "))
+	}
+}
+
+func (g *generator) server(w *bytes.Buffer, sc *ServiceContract, name string, routes []generatedRoute) {
+	fmt.Fprintf(w, "// %sServer handles the routes of the %s service\n", name, sc.Label)
+	fmt.Fprintf(w, "type %sServer interface {\n", name)
+	for _, route := range routes {
+		if route.contract.Description != "" {
+			fmt.Fprintf(w, "// %s %s\n", route.name, lowerFirst(route.contract.Description))
+		}
+		if route.dataType != "" {
+			fmt.Fprintf(w, "%s(ctx *lotus.Context, data %s)\n", route.name, route.dataType)
+		} else {
+			fmt.Fprintf(w, "%s(ctx *lotus.Context)\n", route.name)
+		}
+	}
+	w.WriteString("}\n\n")
+
+	fmt.Fprintf(w, "// Setup%sServer sets up the methods of server as the routes of service, which must be created with the\n", name)
+	fmt.Fprintf(w, "// %sContract. Requests with a payload that can't be decoded are answered with ErrBadRequest\n", name)
+	fmt.Fprintf(w, "func Setup%sServer(service *lotus.Service, server %sServer) {\n", name, name)
+	for _, route := range routes {
+		if route.dataType == "" {
+			fmt.Fprintf(w, "service.SetupRoute(%s.Label, server.%s, nil, nil)\n", route.variable, route.name)
+			continue
+		}
+		fmt.Fprintf(w, "service.SetupRoute(%s.Label, func(ctx *lotus.Context) {\n", route.variable)
+		fmt.Fprintf(w, "data, err := lotus.Payload[%s](ctx)\n", route.dataType)
+		w.WriteString("if err != nil {\nctx.Error(lotus.ErrBadRequest.WithMessage(err.Error()))\nreturn\n}\n")
+		fmt.Fprintf(w, "server.%s(ctx, data)\n}, nil, nil)\n", route.name)
+	}
+	w.WriteString("}\n\n")
+}
+
+// goType returns the Go type of the schema, declaring the structs it needs. name is used for objects without title
+func (g *generator) goType(s *Schema, name string) string {
+	if s.Ref != "" {
+		typ := goName(s.Ref[strings.LastIndex(s.Ref, "/")+1:])
+		if s.Nullable {
+			return "*" + typ
+		}
+		return typ
+	}
+
+	var typ string
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			typ = "time.Time"
+		case "duration":
+			g.imports["time"] = true
+			typ = "time.Duration"
+		case "byte":
+			typ = "[]byte"
+		case "binary":
+			g.imports["mime/multipart"] = true
+			return "*multipart.FileHeader"
+		default:
+			typ = "string"
+		}
+	case "integer":
+		typ = "int"
+		if s.Format == "int32" {
+			typ = "int32"
+		}
+	case "number":
+		typ = "float64"
+		if s.Format == "float" {
+			typ = "float32"
+		}
+	case "boolean":
+		typ = "bool"
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(s.Items, name+"Item")
+	case "object":
+		switch {
+		case len(s.Properties) > 0 || s.Title != "":
+			typ = g.declareStruct(s, name)
+		case s.AdditionalProperties != nil:
+			return "map[string]" + g.goType(s.AdditionalProperties, name+"Value")
+		default:
+			return "map[string]interface{}"
+		}
+	default:
+		return "interface{}"
+	}
+	if s.Nullable {
+		return "*" + typ
+	}
+	return typ
+}
+
+// declareStruct declares the struct of an object schema. Structs with the same name and different fields are
+// declared with a numeric suffix
+func (g *generator) declareStruct(s *Schema, name string) string {
+	if s.Title != "" {
+		name = goName(s.Title)
+	}
+
+	properties := s.PropertyOrder
+	if len(properties) == 0 {
+		for property := range s.Properties {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+	}
+	var fields strings.Builder
+	for _, property := range properties {
+		schema := s.Properties[property]
+		fieldName := goName(property)
+		fieldType := g.goType(schema, name+fieldName)
+		if fieldType == name {
+			// structs can only refer to themselves through pointers
+			fieldType = "*" + fieldType
+		}
+		fmt.Fprintf(&fields, "%s %s", fieldName, fieldType)
+		var tags []string
+		if fieldName != property {
+			tags = append(tags, fmt.Sprintf("json:%q", property), fmt.Sprintf("msgpack:%q", property))
+		}
+		if schema.PathParam != "" {
+			tags = append(tags, fmt.Sprintf("%s:%q", tagName, "path="+schema.PathParam))
+		}
+		if rules := validationTag(schema, contains(s.Required, property)); rules != "" {
+			tags = append(tags, fmt.Sprintf("%s:%q", validateTagName, rules))
+		}
+		if len(tags) > 0 {
+			fmt.Fprintf(&fields, " `%s`", strings.Join(tags, " "))
+		}
+		fields.WriteByte('\n')
+	}
+
+	for i := 1; ; i++ {
+		typeName := name
+		if i > 1 {
+			typeName = name + strconv.Itoa(i)
+		}
+		decl := fmt.Sprintf("type %s struct {\n%s}\n\n", typeName, fields.String())
+		existing, ok := g.types[typeName]
+		if !ok {
+			g.types[typeName] = decl
+			g.typeOrder = append(g.typeOrder, typeName)
+			return typeName
+		}
+		if existing == decl {
+			return typeName
+		}
+	}
+}
+
+// zeroValue returns an expression of the zero value of typ used as route Data
+func (g *generator) zeroValue(typ string) string {
+	if _, ok := g.types[typ]; ok || strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[") {
+		return typ + "{}"
+	}
+	return "*new(" + typ + ")"
+}
+
+// validationTag rebuilds the validate tag of a field from its schema
+func validationTag(s *Schema, required bool) string {
+	var rules []string
+	if required {
+		rules = append(rules, "required")
+	}
+	if s.OmitEmpty {
+		rules = append(rules, "omitempty")
+	}
+	switch {
+	case s.Minimum != nil || s.Maximum != nil:
+		rules = append(rules, boundRules(floatPtrString(s.Minimum), floatPtrString(s.Maximum))...)
+	case s.MinLength != nil || s.MaxLength != nil:
+		rules = append(rules, boundRules(intPtrString(s.MinLength), intPtrString(s.MaxLength))...)
+	case s.MinItems != nil || s.MaxItems != nil:
+		rules = append(rules, boundRules(intPtrString(s.MinItems), intPtrString(s.MaxItems))...)
+	}
+	if len(s.Enum) > 0 {
+		rules = append(rules, "enum="+strings.Join(s.Enum, "|"))
+	}
+	if s.Pattern != "" {
+		rules = append(rules, "regex="+s.Pattern)
+	}
+	return strings.Join(rules, ",")
+}
+
+func boundRules(min, max string) []string {
+	if min != "" && min == max {
+		return []string{"len=" + min}
+	}
+	var rules []string
+	if min != "" {
+		rules = append(rules, "min="+min)
+	}
+	if max != "" {
+		rules = append(rules, "max="+max)
+	}
+	return rules
+}
+
+func floatPtrString(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func intPtrString(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+// unboundParams returns the params not bound to a property of the schema
+func unboundParams(params []string, s *Schema) []string {
+	var result []string
+	for _, param := range params {
+		bound := false
+		for _, property := range s.Properties {
+			if property.PathParam == param {
+				bound = true
+			}
+		}
+		if !bound {
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
+// paramNames returns the names of the client method arguments of the route params
+func paramNames(params []string) []string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		name := lowerFirst(goName(param))
+		switch {
+		case name == "ctx" || name == "data" || name == "out" || name == "c" || token.IsKeyword(name):
+			name += "Param"
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// goName converts a label or field name into an exported Go identifier, e.g. "user_id" into "UserId"
+func goName(s string) string {
+	var builder strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	name := builder.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func writeDoc(w *bytes.Buffer, name, summary, description string) {
+	fmt.Fprintf(w, "// %s %s", name, summary)
+	if description != "" {
+		fmt.Fprintf(w, ". %s", description)
+	}
+	w.WriteString("\n")
+}
+
+func methodConstant(method Method) string {
+	if method == ANY {
+		return "lotus.ANY"
+	}
+	for _, m := range standardMethods {
+		if m == method {
+			return "lotus." + string(method)
+		}
+	}
+	return fmt.Sprintf("lotus.Method(%q)", method)
+}
+
+func dataTypeConstant(dataType DataType) string {
+	switch dataType {
+	case JSON:
+		return "lotus.JSON"
+	case Binary:
+		return "lotus.Binary"
+	case Form:
+		return "lotus.Form"
+	case MultipartForm:
+		return "lotus.MultipartForm"
+	}
+	return fmt.Sprintf("lotus.DataType(%q)", dataType)
+}
+
+func protocolConstant(p protocol) string {
+	switch p {
+	case HTTP:
+		return "lotus.HTTP"
+	case HTTPS:
+		return "lotus.HTTPS"
+	}
+	return strconv.Quote(string(p))
+}
@@ -0,0 +1,45 @@
+package lotus
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/parser"
+	"go/token"
+	"os"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	contract, err := LoadContract("test/generated/contract.yaml")
+	assert.Nil(t, err, "Loading the contract file must not return an error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := GenerateGo(contract, "generated")
+	assert.Nil(t, err, "Generating code must not return an error")
+	golden, _ := os.ReadFile("test/generated/contract.go")
+	assert.Equal(t, string(golden), string(src), "The generated code must be up to date, run go generate ./test/generated")
+
+	src, err = GenerateGo(&OpenAPIContract, "users")
+	assert.Nil(t, err, "Generating code from a Go contract must not return an error")
+	_, err = parser.ParseFile(token.NewFileSet(), "users.go", src, 0)
+	assert.Nil(t, err, "The generated code must be valid Go")
+
+	code := string(src)
+	assert.Contains(t, code, "type SignUp struct {", "Data types must be named after the Go types")
+	assert.Contains(t, code, "Name      string   `validate:\"required,min=2,max=10\"`", "Validation rules must be kept")
+	assert.Contains(t, code, "ID   int    `lotus:\"path=id\"`", "Path bindings must be kept")
+	assert.Contains(t, code, "Secondary *Address", "Nullable structs must be pointers")
+	assert.Contains(t, code, "type TreeNode struct {\n\tName     string\n\tChildren []TreeNode\n}", "Recursive types must be declared with the names of the route codec")
+	assert.Contains(t, code, "Data: SignUp{},", "Route contracts must use the generated types")
+	assert.Contains(t, code, "func (c *UsersClient) GetUser(ctx context.Context, data UserParams, out interface{}) error {", "Typed client methods must be generated")
+	assert.Contains(t, code, "GetUser(ctx *lotus.Context, data UserParams)", "Server methods must receive the typed payload")
+	assert.Contains(t, code, "func SetupUsersServer(service *lotus.Service, server UsersServer) {")
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "UserId", goName("user_id"))
+	assert.Equal(t, "GetUser", goName("GetUser"))
+	assert.Equal(t, "RandomStrings", goName("random-strings"))
+	assert.Equal(t, "X1st", goName("1st"))
+}
@@ -0,0 +1,290 @@
+package lotus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind classifies a ContractChange
+type ChangeKind string
+
+const (
+	// BreakingChange is a change that breaks clients of the previous contract
+	BreakingChange ChangeKind = "breaking"
+	// AdditiveChange is a change clients of the previous contract are compatible with
+	AdditiveChange ChangeKind = "additive"
+)
+
+// ContractDescription describes every service of a Contract. It's the serialized snapshot compared by DiffContracts
+type ContractDescription struct {
+	Services []ServiceDescription
+}
+
+// ContractChange is a difference between two versions of a contract
+type ContractChange struct {
+	Kind    ChangeKind
+	Service string
+	// Route is the label of the changed route, empty for service level changes
+	Route string
+	// Field is the path of the changed Data field, e.g. "Address.Zip" or "Contacts[].Street"
+	Field   string
+	Message string
+}
+
+func (c ContractChange) String() string {
+	var builder strings.Builder
+	builder.WriteString(string(c.Kind))
+	builder.WriteString(": ")
+	builder.WriteString(c.Service)
+	if c.Route != "" {
+		builder.WriteString(" ")
+		builder.WriteString(c.Route)
+	}
+	if c.Field != "" {
+		builder.WriteString(" ")
+		builder.WriteString(c.Field)
+	}
+	builder.WriteString(" ")
+	builder.WriteString(c.Message)
+	return builder.String()
+}
+
+// ContractDiff lists the changes between two versions of a contract
+type ContractDiff struct {
+	Changes []ContractChange
+}
+
+// Breaking returns the breaking changes of the diff
+func (d *ContractDiff) Breaking() []ContractChange {
+	var changes []ContractChange
+	for _, c := range d.Changes {
+		if c.Kind == BreakingChange {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// IsCompatible reports whether clients of the previous contract are compatible with the new one
+func (d *ContractDiff) IsCompatible() bool {
+	return len(d.Breaking()) == 0
+}
+
+// Describe returns the description of every service of the contract
+func (c *Contract) Describe() *ContractDescription {
+	desc := &ContractDescription{}
+	for i := range c.Services {
+		desc.Services = append(desc.Services, *c.Services[i].Describe())
+	}
+	return desc
+}
+
+// ParseContractDescription decodes a JSON snapshot of a ContractDescription. Snapshots of a single
+// ServiceDescription, as served on ContractPath, are accepted as well
+func ParseContractDescription(data []byte) (*ContractDescription, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	desc := &ContractDescription{}
+	if _, ok := fields["Services"]; ok {
+		err := json.Unmarshal(data, desc)
+		return desc, err
+	}
+	service := ServiceDescription{}
+	if err := json.Unmarshal(data, &service); err != nil {
+		return nil, err
+	}
+	if service.Label == "" {
+		return nil, fmt.Errorf("snapshot is neither a contract nor a service description")
+	}
+	desc.Services = []ServiceDescription{service}
+	return desc, nil
+}
+
+// DiffContracts compares two contract versions. Removed services and routes, changed methods, paths and body types,
+// removed or retyped Data fields and new required fields are breaking changes. Anything added is additive
+func DiffContracts(previous, next *ContractDescription) *ContractDiff {
+	diff := &ContractDiff{}
+	for i := range previous.Services {
+		old := &previous.Services[i]
+		current := next.service(old.Label)
+		if current == nil {
+			diff.add(BreakingChange, old.Label, "", "", "service removed")
+			continue
+		}
+		diff.diffServices(old, current)
+	}
+	for _, s := range next.Services {
+		if previous.service(s.Label) == nil {
+			diff.add(AdditiveChange, s.Label, "", "", "service added")
+		}
+	}
+	return diff
+}
+
+// DiffServices compares two versions of a service
+func DiffServices(previous, next *ServiceDescription) *ContractDiff {
+	diff := &ContractDiff{}
+	diff.diffServices(previous, next)
+	return diff
+}
+
+func (desc *ContractDescription) service(label string) *ServiceDescription {
+	for i := range desc.Services {
+		if desc.Services[i].Label == label {
+			return &desc.Services[i]
+		}
+	}
+	return nil
+}
+
+func (d *ContractDiff) add(kind ChangeKind, service, route, field, format string, args ...interface{}) {
+	d.Changes = append(d.Changes, ContractChange{
+		Kind:    kind,
+		Service: service,
+		Route:   route,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *ContractDiff) diffServices(previous, next *ServiceDescription) {
+	service := previous.Label
+	if previous.Protocol != next.Protocol {
+		d.add(BreakingChange, service, "", "", "protocol changed from %s to %s", previous.Protocol, next.Protocol)
+	}
+	if previous.Namespace != next.Namespace {
+		d.add(BreakingChange, service, "", "", "namespace changed from %q to %q", previous.Namespace, next.Namespace)
+	}
+	if previous.Version != next.Version {
+		d.add(BreakingChange, service, "", "", "version changed from %s to %s", previous.Version, next.Version)
+	}
+
+	for _, old := range previous.Routes {
+		i := next.routeIndex(old.Label)
+		if i < 0 {
+			d.add(BreakingChange, service, old.Label, "", "route removed")
+			continue
+		}
+		current := next.Routes[i]
+		if old.Method != current.Method {
+			d.add(BreakingChange, service, old.Label, "", "method changed from %s to %s", old.Method, current.Method)
+		}
+		if routePattern(old.Path) != routePattern(current.Path) {
+			d.add(BreakingChange, service, old.Label, "", "path changed from %s to %s", old.Path, current.Path)
+		}
+		if old.BodyType != current.BodyType {
+			d.add(BreakingChange, service, old.Label, "", "body type changed from %s to %s", old.BodyType, current.BodyType)
+		}
+		d.diffSchemas(service, old.Label, "", old.Schema, current.Schema)
+	}
+	for _, route := range next.Routes {
+		if previous.routeIndex(route.Label) < 0 {
+			d.add(AdditiveChange, service, route.Label, "", "route added")
+		}
+	}
+
+	names := make([]string, 0, len(previous.Definitions))
+	for name := range previous.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// removed definitions are reported by the fields referencing them
+	for _, name := range names {
+		if def, ok := next.Definitions[name]; ok {
+			d.diffSchemas(service, "", name, previous.Definitions[name], def)
+		}
+	}
+}
+
+// diffSchemas compares the schemas of a field. Fields only change from the point of view of a client sending them,
+// so new optional fields are additive and new required fields are breaking
+func (d *ContractDiff) diffSchemas(service, route, field string, previous, next *Schema) {
+	switch {
+	case previous == nil && next == nil:
+		return
+	case previous == nil:
+		if len(next.Required) > 0 {
+			d.add(BreakingChange, service, route, field, "data added with required fields %s", strings.Join(next.Required, ", "))
+		} else {
+			d.add(AdditiveChange, service, route, field, "data added")
+		}
+		return
+	case next == nil:
+		d.add(BreakingChange, service, route, field, "data removed")
+		return
+	}
+
+	if previous.Ref != "" || next.Ref != "" {
+		if previous.Ref != next.Ref {
+			d.add(BreakingChange, service, route, field, "type changed from %s to %s", schemaType(previous), schemaType(next))
+		}
+		return
+	}
+	if previous.Type != next.Type || previous.Format != next.Format {
+		d.add(BreakingChange, service, route, field, "type changed from %s to %s", schemaType(previous), schemaType(next))
+		return
+	}
+	if previous.PathParam != next.PathParam {
+		d.add(BreakingChange, service, route, field, "path param changed from %q to %q", previous.PathParam, next.PathParam)
+	}
+
+	if previous.Items != nil || next.Items != nil {
+		d.diffSchemas(service, route, field+"[]", previous.Items, next.Items)
+	}
+	if previous.AdditionalProperties != nil || next.AdditionalProperties != nil {
+		d.diffSchemas(service, route, field+"{}", previous.AdditionalProperties, next.AdditionalProperties)
+	}
+
+	for _, name := range previous.PropertyOrder {
+		path := fieldPath(field, name)
+		property, ok := next.Properties[name]
+		if !ok {
+			d.add(BreakingChange, service, route, path, "field removed")
+			continue
+		}
+		if !contains(previous.Required, name) && contains(next.Required, name) {
+			d.add(BreakingChange, service, route, path, "field became required")
+		}
+		d.diffSchemas(service, route, path, previous.Properties[name], property)
+	}
+	for _, name := range next.PropertyOrder {
+		if _, ok := previous.Properties[name]; ok {
+			continue
+		}
+		if contains(next.Required, name) {
+			d.add(BreakingChange, service, route, fieldPath(field, name), "required field added")
+		} else {
+			d.add(AdditiveChange, service, route, fieldPath(field, name), "field added")
+		}
+	}
+}
+
+func fieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// schemaType returns a short representation of the schema type, e.g. "integer/int64" or "#/components/schemas/Node"
+func schemaType(s *Schema) string {
+	if s.Ref != "" {
+		return s.Ref
+	}
+	if s.Format != "" {
+		return s.Type + "/" + s.Format
+	}
+	if s.Type == "" {
+		return "any"
+	}
+	return s.Type
+}
+
+// routePattern replaces the route params of a path so paths only differing by param names are equal
+func routePattern(path string) string {
+	return routerParamReg.ReplaceAllString(path, ":")
+}
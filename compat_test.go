@@ -0,0 +1,108 @@
+package lotus
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type OrderV1 struct {
+	ID       int `lotus:"path=id"`
+	Quantity int
+	Note     string
+	Items    []OrderItemV1
+}
+
+type OrderItemV1 struct {
+	SKU string
+}
+
+type OrderV2 struct {
+	ID       int `lotus:"path=order"`
+	Quantity string
+	Coupon   string
+	Customer string `validate:"required"`
+	Items    []OrderItemV2
+}
+
+type OrderItemV2 struct {
+	SKU   string `validate:"required"`
+	Price float64
+}
+
+func orderContract(version string, routes ...RouteContract) *ContractDescription {
+	contract := Contract{Services: []ServiceContract{{Label: "Orders", Version: version, RoutesContracts: routes}}}
+	return contract.Describe()
+}
+
+func changes(diff *ContractDiff) []string {
+	result := make([]string, len(diff.Changes))
+	for i, c := range diff.Changes {
+		result[i] = c.String()
+	}
+	return result
+}
+
+func TestDiffContracts(t *testing.T) {
+	previous := orderContract("v1",
+		RouteContract{Label: "GetOrder", Path: "/orders/:id", Data: OrderV1{}},
+		RouteContract{Label: "UpdateOrder", Method: PUT, Path: "/orders/:id", Data: OrderV1{}},
+		RouteContract{Label: "DeleteOrder", Method: DELETE, Path: "/orders/:id"},
+	)
+
+	diff := DiffContracts(previous, previous)
+	assert.Empty(t, diff.Changes, "Equal contracts must not have changes")
+	assert.True(t, diff.IsCompatible())
+
+	renamedParam := orderContract("v1",
+		RouteContract{Label: "GetOrder", Path: "/orders/:order", Data: OrderV1{}},
+		RouteContract{Label: "UpdateOrder", Method: PUT, Path: "/orders/:id", Data: OrderV1{}},
+		RouteContract{Label: "DeleteOrder", Method: DELETE, Path: "/orders/:id"},
+		RouteContract{Label: "ListOrders", Path: "/orders"},
+	)
+	diff = DiffContracts(previous, renamedParam)
+	assert.Equal(t, []string{"additive: Orders ListOrders route added"}, changes(diff), "Renaming path params must not be a change")
+	assert.True(t, diff.IsCompatible(), "Additive changes must be compatible")
+
+	next := orderContract("v2",
+		RouteContract{Label: "GetOrder", Method: POST, Path: "/orders/:order", Data: OrderV2{}},
+		RouteContract{Label: "UpdateOrder", Method: PUT, Path: "/order/:id", DataHandlerConfig: DataHandlerConfig{BodyType: JSON}},
+	)
+	diff = DiffContracts(previous, next)
+	assert.False(t, diff.IsCompatible(), "Breaking changes must not be compatible")
+	assert.Equal(t, []string{
+		"breaking: Orders version changed from v1 to v2",
+		"breaking: Orders GetOrder method changed from GET to POST",
+		"breaking: Orders GetOrder ID path param changed from \"id\" to \"order\"",
+		"breaking: Orders GetOrder Quantity type changed from integer/int64 to string",
+		"breaking: Orders GetOrder Note field removed",
+		"breaking: Orders GetOrder Items[].SKU field became required",
+		"additive: Orders GetOrder Items[].Price field added",
+		"additive: Orders GetOrder Coupon field added",
+		"breaking: Orders GetOrder Customer required field added",
+		"breaking: Orders UpdateOrder path changed from /orders/:id to /order/:id",
+		"breaking: Orders UpdateOrder body type changed from application/msgpack to application/json",
+		"breaking: Orders UpdateOrder data removed",
+		"breaking: Orders DeleteOrder route removed",
+	}, changes(diff), "Every change must be reported")
+	assert.Len(t, diff.Breaking(), 11)
+
+	diff = DiffContracts(previous, &ContractDescription{})
+	assert.Equal(t, []string{"breaking: Orders service removed"}, changes(diff))
+}
+
+func TestParseContractDescription(t *testing.T) {
+	contract := orderContract("v1", RouteContract{Label: "GetOrder", Path: "/orders/:id", Data: OrderV1{}})
+	b, _ := json.Marshal(contract)
+	parsed, err := ParseContractDescription(b)
+	assert.Nil(t, err, "Parsing a contract snapshot must not return an error")
+	assert.Equal(t, contract, parsed, "The snapshot must be decoded")
+
+	b, _ = json.Marshal(contract.Services[0])
+	parsed, err = ParseContractDescription(b)
+	assert.Nil(t, err, "Parsing a service snapshot must not return an error")
+	assert.Equal(t, contract, parsed, "Service snapshots must be wrapped on a contract")
+
+	_, err = ParseContractDescription([]byte(`{"Foo": "bar"}`))
+	assert.NotNil(t, err, "Parsing an unknown snapshot must return an error")
+}
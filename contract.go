@@ -1,7 +1,7 @@
 package lotus
 
 type Contract struct {
-	Services []ServiceContract
+	Services []ServiceContract `json:"services" yaml:"services"`
 }
 
 func (c *Contract) serviceContract(label string) *ServiceContract {
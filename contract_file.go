@@ -0,0 +1,231 @@
+package lotus
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Contract files hold a Contract, or a single ServiceContract, as YAML or JSON so it can be shared with services and
+tools that don't import the Go contract. Route Data can't be serialized, so its schema is written instead:
+
+	services:
+	  - label: Users
+	    namespace: api
+	    port: 8080
+	    version: v1
+	    environments:
+	      production:
+	        host: users.internal
+	        port: 443
+	        protocol: https
+	    routes:
+	      - label: GetUser
+	        path: /users/:id
+	        schema:
+	          type: object
+	          properties:
+	            ID: {type: integer, format: int64, x-path-param: id}
+	      - label: SignUp
+	        method: POST
+	        path: /users
+	        dataHandler:
+	          bodyType: application/json
+*/
+
+// ServiceOverride holds the address of a service on an environment. Empty fields keep the contract values
+type ServiceOverride struct {
+	Protocol protocol `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Host     string   `json:"host,omitempty" yaml:"host,omitempty"`
+	Port     int      `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+// LoadContract reads a Contract from a YAML or JSON file
+func LoadContract(path string) (*Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contract, err := ParseContract(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return contract, nil
+}
+
+// ParseContract decodes a Contract from YAML or JSON data
+func ParseContract(data []byte) (*Contract, error) {
+	contract := &Contract{}
+	if err := yaml.Unmarshal(data, contract); err != nil {
+		return nil, err
+	}
+	if err := contract.validate(); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// LoadServiceContract reads a ServiceContract from a YAML or JSON file
+func LoadServiceContract(path string) (*ServiceContract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := ParseServiceContract(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// ParseServiceContract decodes a ServiceContract from YAML or JSON data
+func ParseServiceContract(data []byte) (*ServiceContract, error) {
+	sc := &ServiceContract{}
+	if err := yaml.Unmarshal(data, sc); err != nil {
+		return nil, err
+	}
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Save writes the contract to a file. The file is encoded as JSON when its extension is .json and as YAML otherwise
+func (c *Contract) Save(path string) error {
+	return saveContractFile(path, c.withSchemas())
+}
+
+// Save writes the service contract to a file. The file is encoded as JSON when its extension is .json and as YAML
+// otherwise
+func (sc *ServiceContract) Save(path string) error {
+	return saveContractFile(path, sc.withSchemas())
+}
+
+// MarshalContract encodes the contract as YAML, replacing route Data by their schemas
+func MarshalContract(c *Contract) ([]byte, error) {
+	return yaml.Marshal(c.withSchemas())
+}
+
+// WithEnvironment returns a copy of the contract with the overrides of the environment applied to the services
+// declaring it. It returns an error if no service declares the environment
+func (c *Contract) WithEnvironment(name string) (*Contract, error) {
+	result := &Contract{Services: make([]ServiceContract, len(c.Services))}
+	found := false
+	for i := range c.Services {
+		result.Services[i] = c.Services[i]
+		if override, ok := c.Services[i].Environments[name]; ok {
+			result.Services[i].applyOverride(override)
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("environment %s not found", name)
+	}
+	return result, nil
+}
+
+// WithEnvironment returns a copy of the service contract with the overrides of the environment applied
+func (sc *ServiceContract) WithEnvironment(name string) (*ServiceContract, error) {
+	override, ok := sc.Environments[name]
+	if !ok {
+		return nil, fmt.Errorf("environment %s not found on service %s", name, sc.Label)
+	}
+	result := *sc
+	result.applyOverride(override)
+	return &result, nil
+}
+
+func (sc *ServiceContract) applyOverride(override ServiceOverride) {
+	if override.Protocol != "" {
+		sc.Protocol = override.Protocol
+	}
+	if override.Host != "" {
+		sc.Host = override.Host
+	}
+	if override.Port != 0 {
+		sc.Port = override.Port
+	}
+}
+
+func saveContractFile(path string, v interface{}) error {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		data, err = yaml.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// withSchemas returns a copy of the contract where the routes Data are replaced by their schemas
+func (c *Contract) withSchemas() *Contract {
+	result := &Contract{Services: make([]ServiceContract, len(c.Services))}
+	for i := range c.Services {
+		result.Services[i] = *c.Services[i].withSchemas()
+	}
+	return result
+}
+
+func (sc *ServiceContract) withSchemas() *ServiceContract {
+	result := *sc
+	result.RoutesContracts = make([]RouteContract, len(sc.RoutesContracts))
+	for i, route := range sc.RoutesContracts {
+		route.Schema, route.Definitions = route.schema()
+		route.Data = nil
+		result.RoutesContracts[i] = route
+	}
+	return &result
+}
+
+func (c *Contract) validate() error {
+	labels := map[string]bool{}
+	for i := range c.Services {
+		sc := &c.Services[i]
+		if labels[sc.Label] {
+			return fmt.Errorf("duplicated service %s", sc.Label)
+		}
+		labels[sc.Label] = true
+		if err := sc.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the service contract read from a file, upper casing the routes methods
+func (sc *ServiceContract) validate() error {
+	if sc.Label == "" {
+		return fmt.Errorf("service without label")
+	}
+	switch sc.Protocol {
+	case "", HTTP, HTTPS:
+	default:
+		return fmt.Errorf("service %s has an unsupported protocol %q", sc.Label, sc.Protocol)
+	}
+	labels := map[string]bool{}
+	for i := range sc.RoutesContracts {
+		route := &sc.RoutesContracts[i]
+		switch {
+		case route.Label == "":
+			return fmt.Errorf("service %s has a route without label", sc.Label)
+		case labels[route.Label]:
+			return fmt.Errorf("service %s has a duplicated route %s", sc.Label, route.Label)
+		case !strings.HasPrefix(route.Path, "/"):
+			return fmt.Errorf("route %s path must begin with /", route.Label)
+		}
+		labels[route.Label] = true
+		route.Method = Method(strings.ToUpper(string(route.Method)))
+		if err := route.validateMethod(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
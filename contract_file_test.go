@@ -0,0 +1,104 @@
+package lotus
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+const usersContractFile = `
+services:
+  - label: Users
+    namespace: api
+    port: 8080
+    version: v1
+    environments:
+      production:
+        protocol: https
+        host: users.internal
+        port: 443
+    routes:
+      - label: GetUser
+        path: /users/:id
+        schema:
+          type: object
+          properties:
+            ID: {type: integer, format: int64, x-path-param: id}
+      - label: SignUp
+        method: post
+        path: /users
+        dataHandler:
+          bodyType: application/json
+  - label: Billing
+    port: 8081
+`
+
+func TestParseContract(t *testing.T) {
+	contract, err := ParseContract([]byte(usersContractFile))
+	assert.Nil(t, err, "Parsing a valid contract must not return an error")
+	assert.Len(t, contract.Services, 2)
+
+	users := contract.Services[0]
+	assert.Equal(t, "Users", users.Label)
+	assert.Equal(t, "localhost:8080", users.address(), "Missing fields must use the defaults")
+	assert.Equal(t, "/api/v1", users.Suffix())
+	assert.Equal(t, Method(POST), users.RoutesContracts[1].Method, "Methods must be upper cased")
+	assert.Equal(t, JSON, users.RoutesContracts[1].DataType())
+	assert.Equal(t, "id", users.RoutesContracts[0].Schema.Properties["ID"].PathParam, "Schemas must be loaded")
+
+	production, err := contract.WithEnvironment("production")
+	assert.Nil(t, err, "Applying a declared environment must not return an error")
+	assert.Equal(t, "https://users.internal:443/api/v1/users", mustRouteUrl(t, &production.Services[0], "SignUp"), "Overrides must be applied")
+	assert.Equal(t, "localhost:8081", production.Services[1].address(), "Services without overrides must keep their address")
+	assert.Equal(t, "localhost:8080", contract.Services[0].address(), "The original contract must not be changed")
+
+	_, err = contract.WithEnvironment("staging")
+	assert.NotNil(t, err, "Applying an unknown environment must return an error")
+
+	invalid := []string{
+		`services: [{label: A}, {label: A}]`,
+		`services: [{port: 80}]`,
+		`services: [{label: A, protocol: tcp}]`,
+		`services: [{label: A, routes: [{label: R, path: /r, method: FETCH}]}]`,
+		`services: [{label: A, routes: [{label: R, path: r}]}]`,
+		`services: [{label: A, routes: [{label: R, path: /r}, {label: R, path: /s}]}]`,
+		`services: {label: A}`,
+	}
+	for _, data := range invalid {
+		_, err = ParseContract([]byte(data))
+		assert.NotNil(t, err, "Parsing an invalid contract must return an error: %s", data)
+	}
+}
+
+func mustRouteUrl(t *testing.T, sc *ServiceContract, label string) string {
+	url, err := sc.RouteUrl(label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url
+}
+
+func TestSaveContract(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"contract.yaml", "contract.json"} {
+		path := filepath.Join(dir, name)
+		err := OpenAPIContract.Save(path)
+		assert.Nil(t, err, "Saving a contract must not return an error")
+
+		loaded, err := LoadContract(path)
+		assert.Nil(t, err, "Loading a saved contract must not return an error")
+		assert.Nil(t, loaded.Services[0].RoutesContracts[0].Data)
+		assert.Equal(t, OpenAPIContract.OpenAPI(), loaded.OpenAPI(), "Loaded contracts must keep the Data schemas of %s", name)
+		assert.True(t, DiffContracts(OpenAPIContract.Describe(), loaded.Describe()).IsCompatible(), "Loaded contracts must be compatible")
+	}
+
+	path := filepath.Join(dir, "service.yaml")
+	err := DescribedServiceContract.Save(path)
+	assert.Nil(t, err, "Saving a service contract must not return an error")
+	loaded, err := LoadServiceContract(path)
+	assert.Nil(t, err, "Loading a saved service contract must not return an error")
+	assert.Equal(t, DescribedServiceContract.Describe(), loaded.Describe(), "Loaded service contracts must be described as the original one")
+
+	_, err = LoadContract(filepath.Join(dir, "missing.yaml"))
+	assert.NotNil(t, err, "Loading a missing file must return an error")
+}
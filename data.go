@@ -1,15 +1,26 @@
 package lotus
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack"
 	"net/url"
 	"reflect"
+	"strconv"
+	"time"
 )
 
 type DataType string
 
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
 const (
 	// JSON http header for json data
 	JSON DataType = "application/json"
@@ -24,27 +35,78 @@ const (
 	MultipartForm DataType = "multipart/form-data"
 )
 
-type Payload interface{} // This will be much better with generics
-
 type ServiceRequest struct {
 	RouteParams map[string]string
 	QueryParams map[string]string
-	Body        Payload
-	DataType    DataType
+	// Body is the route Data. Requests with methods without body, e.g. GET, send it as query args
+	Body     interface{}
+	DataType DataType
+	// BalanceKey is hashed by ConsistentHashBalancer to send requests with the same key to the same instance
+	BalanceKey string
+	// IdempotencyKey is sent on the IdempotencyKeyHeader and allows retrying requests with non idempotent methods
+	IdempotencyKey string
+	// Timeout bounds the call, overriding the route and client Timeout
+	Timeout time.Duration
 }
 
 type Context struct {
 	*fasthttp.RequestCtx
 	ServiceClients []ServiceClient
+	// route holds a reference to the Route serving the request
+	route *Route
+	// ctx bounds the request with its deadline
+	ctx context.Context
 }
 
-func (ctx *Context) Payload() (Payload, error) {
-	if payload, ok := ctx.UserValue(DefaultKey).(interface{}); ok {
+// Payload returns the untyped payload decoded by the route DataHandler. Prefer the generic Payload function
+func (ctx *Context) Payload() (interface{}, error) {
+	if payload := ctx.UserValue(ctx.userValueKey()); payload != nil {
 		return payload, nil
 	}
 	return nil, errors.New("fail to convert payload")
 }
 
+// WritePayload encodes v using the route body DataType and writes it as the response body
+func (ctx *Context) WritePayload(v interface{}) error {
+	dataType := DefaultBodyDataType
+	if ctx.route != nil {
+		dataType = ctx.route.DataType()
+	}
+	b, contentType, err := encodeBody(dataType, v)
+	if err != nil {
+		return err
+	}
+	ctx.SetContentType(string(contentType))
+	ctx.SetBody(b)
+	return nil
+}
+
+func (ctx *Context) userValueKey() string {
+	if ctx.route != nil {
+		return ctx.route.userValueKey()
+	}
+	return DefaultKey
+}
+
+// Payload returns the payload decoded by the route DataHandler as a T value. T must match the type of the
+// RouteContract Data (or a pointer to it). Requests without a body return the zero value of T
+func Payload[T any](ctx *Context) (T, error) {
+	var zero T
+	switch data := ctx.UserValue(ctx.userValueKey()).(type) {
+	case nil:
+		return zero, nil
+	case T:
+		return data, nil
+	case *T:
+		if data != nil {
+			return *data, nil
+		}
+		return zero, nil
+	default:
+		return zero, fmt.Errorf("payload of type %T can't be used as %T", data, zero)
+	}
+}
+
 func (ctx *Context) ServiceClient(sub ServiceContract) *ServiceClient {
 	for _, c := range ctx.ServiceClients {
 		if c.Label == sub.Label {
@@ -56,23 +118,154 @@ func (ctx *Context) ServiceClient(sub ServiceContract) *ServiceClient {
 
 type RequestHandler func(ctx *Context)
 
+// TypedRoute is a handler that receives the route payload already decoded as Req and returns a Resp which is
+// written on the response using the route body DataType
+type TypedRoute[Req any, Resp any] func(ctx *Context, req Req) (Resp, error)
+
+// Handler converts the TypedRoute into a RequestHandler that can be used on Service.SetupRoute
+func (handler TypedRoute[Req, Resp]) Handler() RequestHandler {
+	return func(ctx *Context) {
+		req, err := Payload[Req](ctx)
+		if err != nil {
+			ctx.Error(ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		resp, err := handler(ctx, req)
+		if err == nil {
+			err = ctx.WritePayload(resp)
+		}
+		if err != nil {
+			ctx.Error(err)
+		}
+	}
+}
+
+// mediaType returns the DataType of a Content-Type header value, ignoring any parameters
+func mediaType(contentType []byte) DataType {
+	if i := bytes.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return DataType(bytes.TrimSpace(contentType))
+}
+
+func encodeBody(dataType DataType, body interface{}) ([]byte, DataType, error) {
+	switch dataType {
+	case JSON:
+		b, err := json.Marshal(body)
+		return b, JSON, err
+	case Form:
+		m, err := dataToUrlValues(body)
+		if err != nil {
+			return nil, Form, err
+		}
+		return []byte(m.Encode()), Form, nil
+	default:
+		b, err := msgpack.Marshal(body)
+		return b, Binary, err
+	}
+}
+
+// newData returns a pointer to a new value of typ, or to a new map when typ is nil
+func newData(typ reflect.Type) reflect.Value {
+	if typ == nil {
+		m := map[string]interface{}{}
+		return reflect.ValueOf(&m)
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return reflect.New(typ)
+}
+
+// dataValue returns the value pointed by ptr as declared by typ. Empty maps are returned as nil
+func dataValue(ptr reflect.Value, typ reflect.Type) interface{} {
+	switch {
+	case typ == nil:
+		if m := ptr.Elem().Interface().(map[string]interface{}); len(m) > 0 {
+			return m
+		}
+		return nil
+	case typ.Kind() == reflect.Ptr:
+		return ptr.Interface()
+	}
+	return ptr.Elem().Interface()
+}
+
+// decodeBody decodes body into the value pointed by out. Unknown data types are ignored
+func decodeBody(dataType DataType, body []byte, out interface{}) error {
+	switch dataType {
+	case JSON, Binary:
+		return unmarshal(dataType, body, out)
+	case Form:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return err
+		}
+		return urlValuesToData(values, out)
+	}
+	return nil
+}
+
+// decodeResponse decodes a response body into out. Bodies of unknown types can be read into a *string or *[]byte
+func decodeResponse(dataType DataType, body []byte, out interface{}) error {
+	switch o := out.(type) {
+	case *[]byte:
+		*o = append((*o)[:0], body...)
+		return nil
+	case *string:
+		*o = string(body)
+		return nil
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	switch dataType {
+	case JSON, Binary, Form:
+		return decodeBody(dataType, body, out)
+	}
+	return fmt.Errorf("unable to decode content type %q", dataType)
+}
+
+func unmarshal(dataType DataType, body []byte, v interface{}) error {
+	switch dataType {
+	case JSON:
+		return json.Unmarshal(body, v)
+	case Binary:
+		return msgpack.Unmarshal(body, v)
+	}
+	return nil
+}
+
 func dataToUrlValues(data interface{}) (form url.Values, err error) {
 	form = map[string][]string{}
-	iValue := reflect.ValueOf(data)
-	switch data.(type) {
-	case map[string]interface{}:
-		for k, v := range data.(map[string]interface{}) {
-			valuesToForm(form, k, reflect.ValueOf(v))
+	iValue := reflect.Indirect(reflect.ValueOf(data))
+	switch iValue.Kind() {
+	case reflect.Invalid:
+		return form, nil
+	case reflect.Map:
+		if iValue.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unable to encode %T as form values", data)
+		}
+		iter := iValue.MapRange()
+		for iter.Next() {
+			v := iter.Value()
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			valuesToForm(form, iter.Key().String(), v)
 		}
 		return form, nil
-	default:
+	case reflect.Struct:
 		for i := 0; i < iValue.NumField(); i++ {
-			k := iValue.Type().Field(i).Name
-			v := iValue.Field(i)
-
-			valuesToForm(form, k, v)
+			field := iValue.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			valuesToForm(form, field.Name, iValue.Field(i))
 		}
 		return form, err
+	default:
+		return nil, fmt.Errorf("unable to encode %T as form values", data)
 	}
 }
 
@@ -80,16 +273,191 @@ func valuesToForm(form url.Values, k string, v reflect.Value) {
 	if form[k] == nil {
 		form[k] = []string{}
 	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
 
 	switch v.Kind() {
 	case reflect.Slice:
+		if v.Type() == bytesType {
+			form[k] = append(form[k], string(v.Bytes()))
+			return
+		}
 		v2 := reflect.ValueOf(v.Interface())
 		for j := 0; j < v2.Len(); j++ {
 			f2 := v2.Index(j)
-			form[k] = append(form[k], fmt.Sprint(f2))
+			form[k] = append(form[k], formatValue(f2))
 		}
 	default:
-		form[k] = append(form[k], fmt.Sprint(v))
+		form[k] = append(form[k], formatValue(v))
+	}
+}
+
+// formatValue formats v as a form value. Times are formatted as RFC3339 so they can be parsed back
+func formatValue(v reflect.Value) string {
+	if v.IsValid() && v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
 	}
+	return fmt.Sprint(v)
 }
 
+// argsToUrlValues converts fasthttp args, such as the query args of a request, to url.Values
+func argsToUrlValues(args *fasthttp.Args) url.Values {
+	values := url.Values{}
+	args.VisitAll(func(k, v []byte) {
+		values.Add(string(k), string(v))
+	})
+	return values
+}
+
+// urlValuesToData is the inverse of dataToUrlValues. It fills the fields of the struct (or map) pointed by out with
+// the values of form, converting them to the field types
+func urlValuesToData(form url.Values, out interface{}) error {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("unable to decode form into non pointer %T", out)
+	}
+	v := ptr.Elem()
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unable to decode form into %T", out)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for k, values := range form {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := valuesToField(elem, values); err != nil {
+				return fmt.Errorf("field %s: %w", k, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Struct:
+		typ := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := typ.Field(i)
+			values, ok := form[field.Name]
+			if !ok || field.PkgPath != "" {
+				continue
+			}
+			if err := valuesToField(v.Field(i), values); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unable to decode form into %T", out)
+}
+
+func valuesToField(v reflect.Value, values []string) error {
+	switch v.Kind() {
+	case reflect.Interface:
+		if len(values) == 1 {
+			v.Set(reflect.ValueOf(values[0]))
+		} else {
+			v.Set(reflect.ValueOf(values))
+		}
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return valueToField(v, lastValue(values))
+		}
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := valueToField(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return valueToField(v, lastValue(values))
+}
+
+func valueToField(v reflect.Value, value string) error {
+	switch v.Type() {
+	case timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := valueToField(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(value))
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseTime parses RFC3339 times as well as the default time.Time String format
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", value); err == nil {
+		return t, nil
+	}
+	return t, err
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
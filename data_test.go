@@ -1,8 +1,12 @@
 package lotus
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/buaazp/fasthttprouter"
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"net"
 	"reflect"
 	"testing"
 	"time"
@@ -363,3 +367,98 @@ func TestInterfaceToUrlValues(t *testing.T) {
 //	err = fasthttp.Do(req, resp)
 //	assert.Nil(t, err, "Sending the request must not return an error")
 //}
+
+type TypedEchoResponse struct {
+	Echo string
+}
+
+func TestTypedPayload(t *testing.T) {
+	route := routeForContract(&RouteContract{Label: "Typed", Path: "/typed", Data: EchoPayload{}}, "/typed", nil, nil)
+	ctx := &Context{RequestCtx: &fasthttp.RequestCtx{}, route: route}
+
+	empty, err := Payload[EchoPayload](ctx)
+	assert.Nil(t, err, "A request without payload must not return an error")
+	assert.Equal(t, EchoPayload{}, empty, "A request without payload must return the zero value")
+
+	ctx.SetUserValue(DefaultKey, EchoPayload{Foo: "foo"})
+	typed, err := Payload[EchoPayload](ctx)
+	assert.Nil(t, err, "Payload must convert to the route Data type")
+	assert.Equal(t, "foo", typed.Foo, "Payload must keep the decoded values")
+
+	ctx.SetUserValue(DefaultKey, &EchoPayload{Bar: "bar"})
+	typed, err = Payload[EchoPayload](ctx)
+	assert.Nil(t, err, "Payload must convert a pointer to the route Data type")
+	assert.Equal(t, "bar", typed.Bar, "Payload must keep the decoded values")
+
+	_, err = Payload[SamplePayload](ctx)
+	assert.NotNil(t, err, "Payload must fail for a different type")
+}
+
+func TestTypedRoute(t *testing.T) {
+	path := "/typed"
+	contract := &RouteContract{
+		Label:             "TypedEcho",
+		Method:            POST,
+		Path:              path,
+		DataHandlerConfig: DataHandlerConfig{BodyType: JSON},
+		Data:              EchoPayload{},
+	}
+	handler := TypedRoute[EchoPayload, TypedEchoResponse](func(ctx *Context, req EchoPayload) (TypedEchoResponse, error) {
+		return TypedEchoResponse{Echo: req.Foo + req.Bar}, nil
+	})
+	route := &Route{RouteContract: contract, RequestHandler: handler.Handler()}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10081"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{Body: EchoPayload{Foo: "foo", Bar: "bar"}})
+	assert.Nil(t, err, "Preparing the request must not return an error")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusOK, resp.StatusCode(), "Typed route must return a 200 status")
+	assert.Equal(t, string(JSON), string(resp.Header.ContentType()), "Typed route must answer using the route DataType")
+
+	var out TypedEchoResponse
+	err = json.Unmarshal(resp.Body(), &out)
+	assert.Nil(t, err, "Typed route response must be decodable")
+	assert.Equal(t, "foobar", out.Echo, "Typed route must receive the decoded request")
+}
+
+func TestUrlValuesToData(t *testing.T) {
+	form, err := dataToUrlValues(payload)
+	assert.Nil(t, err, "Encoding the payload must not return an error")
+
+	var out SamplePayload
+	err = urlValuesToData(form, &out)
+	assert.Nil(t, err, "Decoding the form must not return an error")
+	assert.Equal(t, payload.Foo, out.Foo, "String value must match")
+	assert.Equal(t, payload.FooBar, out.FooBar, "String array value must match")
+	assert.Equal(t, payload.IntValue, out.IntValue, "Int value must match")
+	assert.Equal(t, payload.FloatValue, out.FloatValue, "Float value must match")
+	assert.Equal(t, payload.Boolean, out.Boolean, "Boolean value must match")
+	assert.Equal(t, payload.FloatValues, out.FloatValues, "Float array value must match")
+	assert.True(t, payload.Time.Equal(out.Time), "Time value must match")
+
+	var m map[string]interface{}
+	err = urlValuesToData(form, &m)
+	assert.Nil(t, err, "Decoding the form into a map must not return an error")
+	assert.Equal(t, "foo", m["Foo"], "Single values must be decoded as strings")
+	assert.Equal(t, []string{"foo", "bar"}, m["FooBar"], "Multiple values must be decoded as string slices")
+
+	form.Set("IntValue", "not a number")
+	err = urlValuesToData(form, &out)
+	assert.NotNil(t, err, "Decoding an invalid value must return an error")
+}
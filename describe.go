@@ -0,0 +1,144 @@
+package lotus
+
+import (
+	"bytes"
+	"context"
+	"github.com/valyala/fasthttp"
+)
+
+// ContractPath is the path, relative to the service Suffix, where a Service with ExposeContract serves its
+// ServiceDescription
+const ContractPath = "/_lotus/contract"
+
+// ServiceDescription describes a service contract and the schemas of its payloads
+type ServiceDescription struct {
+	Label       string
+	Description string
+	Protocol    string
+	Address     string
+	Namespace   string
+	Version     string
+	Routes      []RouteDescription
+	// Definitions holds the schemas of recursive types referenced by the route schemas
+	Definitions map[string]*Schema
+}
+
+// RouteDescription describes a route contract and the schema of its Data
+type RouteDescription struct {
+	Label       string
+	Description string
+	Method      Method
+	Path        string
+	BodyType    DataType
+	Schema      *Schema
+	// Registered is true when the route is served by the described Service
+	Registered bool
+}
+
+// Describe returns the description of the contract. Routes are not flagged as registered, use Service.Describe
+// to describe a running service
+func (sc *ServiceContract) Describe() *ServiceDescription {
+	desc := &ServiceDescription{
+		Label:       sc.Label,
+		Description: sc.Description,
+		Protocol:    sc.protocol(),
+		Address:     sc.address(),
+		Namespace:   sc.namespace(),
+		Version:     sc.version(),
+	}
+	for i := range sc.RoutesContracts {
+		desc.addRoute(&sc.RoutesContracts[i], false)
+	}
+	return desc
+}
+
+// Describe returns the description of the service contract flagging the routes it serves. Routes added to the
+// service but missing from the contract are described as well
+func (service *Service) Describe() *ServiceDescription {
+	desc := service.ServiceContract.Describe()
+	for _, route := range service.routes {
+		if i := desc.routeIndex(route.Label); i >= 0 {
+			desc.Routes[i].Registered = true
+			continue
+		}
+		desc.addRoute(route.RouteContract, true)
+	}
+	return desc
+}
+
+func (desc *ServiceDescription) addRoute(route *RouteContract, registered bool) {
+	schema, definitions := route.schema()
+	for name, def := range definitions {
+		if desc.Definitions == nil {
+			desc.Definitions = map[string]*Schema{}
+		}
+		desc.Definitions[name] = def
+	}
+	desc.Routes = append(desc.Routes, RouteDescription{
+		Label:       route.Label,
+		Description: route.Description,
+		Method:      route.method(),
+		Path:        route.Path,
+		BodyType:    route.DataType(),
+		Schema:      schema,
+		Registered:  registered,
+	})
+}
+
+func (desc *ServiceDescription) routeIndex(label string) int {
+	for i, route := range desc.Routes {
+		if route.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// serveContract writes the service description encoded as msgpack when the request accepts it and as JSON otherwise
+func (service *Service) serveContract(ctx *fasthttp.RequestCtx) {
+	dataType := JSON
+	if bytes.Contains(ctx.Request.Header.Peek("Accept"), []byte(Binary)) {
+		dataType = Binary
+	}
+	b, contentType, err := encodeBody(dataType, service.Describe())
+	if err != nil {
+		writeError(ctx, dataType, err)
+		return
+	}
+	ctx.SetContentType(string(contentType))
+	ctx.SetBody(b)
+}
+
+// FetchContract requests the description of a service exposing its contract
+func (sc *ServiceClient) FetchContract(ctx context.Context) (*ServiceDescription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	address, done, err := sc.pick(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	req.SetRequestURI(sc.protocol() + "://" + address + sc.Suffix() + ContractPath)
+	req.Header.Set("Accept", string(Binary))
+
+	deadline, _ := ctx.Deadline()
+	err = sc.do(req, resp, deadline)
+	done(err, resp.StatusCode())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, newResponseError(ContractPath, resp)
+	}
+
+	desc := &ServiceDescription{}
+	err = decodeResponse(mediaType(resp.Header.ContentType()), resp.Body(), desc)
+	return desc, err
+}
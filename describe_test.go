@@ -0,0 +1,83 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+var DescribedServiceContract = ServiceContract{
+	Label:   "Described",
+	Port:    10093,
+	Version: "v1",
+	RoutesContracts: []RouteContract{
+		{Label: "GetUser", Path: "/users/:id/:slot", Data: UserParams{}},
+		{Label: "SignUp", Method: POST, Path: "/users", Data: SignUp{}},
+	},
+}
+
+func TestExposeContract(t *testing.T) {
+	service := Service{ServiceContract: &DescribedServiceContract, ExposeContract: true}
+	service.SetupRoute("SignUp", echo, nil, nil)
+	service.AddRoute(&Route{
+		RouteContract:  &RouteContract{Label: "Tree", Method: PUT, Path: "/tree", Data: TreeNode{}},
+		RequestHandler: echo,
+	})
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &DescribedServiceContract}
+	desc, err := client.FetchContract(context.Background())
+	assert.Nil(t, err, "Fetching the contract must not return an error")
+	assert.Equal(t, "Described", desc.Label)
+	assert.Equal(t, "localhost:10093", desc.Address)
+	assert.Equal(t, "v1", desc.Version)
+	assert.Len(t, desc.Routes, 3, "Contract routes and routes added to the service must be described")
+
+	assert.Equal(t, "GetUser", desc.Routes[0].Label)
+	assert.False(t, desc.Routes[0].Registered, "Routes not served must not be flagged as registered")
+	assert.Equal(t, "id", desc.Routes[0].Schema.Properties["ID"].PathParam, "Route schemas must be described")
+
+	assert.Equal(t, "SignUp", desc.Routes[1].Label)
+	assert.True(t, desc.Routes[1].Registered, "Served routes must be flagged as registered")
+	assert.Equal(t, Method(POST), desc.Routes[1].Method)
+	assert.Equal(t, Binary, desc.Routes[1].BodyType)
+	assert.Equal(t, 18.0, *desc.Routes[1].Schema.Properties["Age"].Minimum, "Validation rules must be described")
+
+	assert.Equal(t, "Tree", desc.Routes[2].Label)
+	assert.True(t, desc.Routes[2].Registered)
+	assert.Contains(t, desc.Definitions, "TreeNode", "Recursive types must be described on the definitions")
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://localhost:10093/v1" + ContractPath)
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Requesting the contract must not return an error")
+	assert.Equal(t, string(JSON), string(resp.Header.ContentType()), "The contract must be served as JSON by default")
+
+	var jsonDesc ServiceDescription
+	err = json.Unmarshal(resp.Body(), &jsonDesc)
+	assert.Nil(t, err, "The JSON contract must be decodable")
+	assert.Equal(t, desc, &jsonDesc, "JSON and msgpack descriptions must match")
+}
+
+func TestContractNotExposedByDefault(t *testing.T) {
+	contract := DescribedServiceContract
+	contract.Port = 10094
+	service := Service{ServiceContract: &contract}
+	service.SetupRoute("SignUp", echo, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &contract}
+	_, err := client.FetchContract(context.Background())
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr), "Fetching a contract not exposed must return a ResponseError")
+	assert.Equal(t, fasthttp.StatusNotFound, respErr.StatusCode)
+}
@@ -0,0 +1,191 @@
+package lotus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"log"
+)
+
+// Error codes of the errors answered by lotus
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRouteNotFound    = "route_not_found"
+	CodeDeadlineExceeded = "deadline_exceeded"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+var (
+	// ErrBadRequest is answered when the request payload can't be decoded
+	ErrBadRequest = &Error{Code: CodeBadRequest, Message: "bad request", Status: fasthttp.StatusBadRequest}
+	// ErrValidationFailed is answered when the request payload fails validation. Its details are the FieldErrors
+	ErrValidationFailed = &Error{Code: CodeValidationFailed, Message: "validation failed", Status: fasthttp.StatusUnprocessableEntity}
+	// ErrPayloadTooLarge is answered when the request body is over the route MaxBodySize
+	ErrPayloadTooLarge = &Error{Code: CodePayloadTooLarge, Message: "request body too large", Status: fasthttp.StatusRequestEntityTooLarge}
+	// ErrRouteNotFound is returned when a route isn't declared by a contract and answered when a request matches no
+	// route
+	ErrRouteNotFound = &Error{Code: CodeRouteNotFound, Message: RouteNotFoundError, Status: fasthttp.StatusNotFound}
+	// ErrDeadlineExceeded is answered when the request deadline expires
+	ErrDeadlineExceeded = &Error{Code: CodeDeadlineExceeded, Message: "request deadline exceeded", Status: fasthttp.StatusGatewayTimeout}
+	// ErrUnavailable is answered when a service called to serve the request is failing
+	ErrUnavailable = &Error{Code: CodeUnavailable, Message: "service unavailable", Status: fasthttp.StatusServiceUnavailable}
+	// ErrInternal is answered when a handler fails with an error that isn't mapped
+	ErrInternal = &Error{Code: CodeInternal, Message: "internal error", Status: fasthttp.StatusInternalServerError}
+)
+
+// Error is an error answered by a service. Context.Error writes it with the route body DataType and ServiceClient
+// calls decode it back, so callers can branch on its Code across services, e.g. errors.Is(err, lotus.ErrRouteNotFound)
+type Error struct {
+	// Code identifies the kind of error. Errors with the same Code match with errors.Is
+	Code string `json:"code" msgpack:"code"`
+	// Message is a human readable description of the error
+	Message string `json:"message" msgpack:"message"`
+	// Details holds additional data about the error, e.g. the failing fields of a validation
+	Details interface{} `json:"details,omitempty" msgpack:"details,omitempty"`
+	// Status is the HTTP status of the response. It's taken from the response status when decoded
+	Status int `json:"-" msgpack:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Is reports whether target is an *Error with the same Code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of the error with message
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// WithDetails returns a copy of the error with details
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// DecodeDetails decodes the Details of a decoded error into v, which must be a pointer
+func (e *Error) DecodeDetails(v interface{}) error {
+	b, err := json.Marshal(e.Details)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (e *Error) status() int {
+	if e.Status == 0 {
+		return fasthttp.StatusInternalServerError
+	}
+	return e.Status
+}
+
+type errorMapping struct {
+	target error
+	err    *Error
+}
+
+// MapError makes Context.Error answer err for the errors matching target with errors.Is on the routes of the
+// service, e.g. to answer sql.ErrNoRows as a 404. Mappings are checked in the order they were added and must be
+// added before Start
+func (service *Service) MapError(target error, err *Error) {
+	service.errorMappings = append(service.errorMappings, errorMapping{target: target, err: err})
+}
+
+// AsError returns the *Error answered for err. Errors wrapping an *Error answer it, validation errors answer ErrValidationFailed with the failing fields, expired deadlines
+// answer ErrDeadlineExceeded and open circuits answer ErrUnavailable. Other errors answer ErrInternal, without their
+// message so internal details don't leak to callers. Context.Error logs them instead
+func AsError(err error) *Error {
+	e, _ := asError(err, nil)
+	return e
+}
+
+// asError returns the *Error answered for err, checking mappings before the errors known by lotus, and whether err
+// is answered as an ErrInternal that isn't mapped
+func asError(err error, mappings []errorMapping) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, false
+	}
+	for _, mapping := range mappings {
+		if errors.Is(err, mapping.target) {
+			return mapping.err, false
+		}
+	}
+
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return ErrValidationFailed.WithDetails(validationErr.Fields), false
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrDeadlineExceeded, false
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrUnavailable, false
+	}
+	return ErrInternal, true
+}
+
+// Error writes the *Error answered for err, as returned by AsError or mapped with Service.MapError, with its status
+// and the route body DataType. Form bodies answer with JSON
+func (ctx *Context) Error(err error) {
+	dataType := DefaultBodyDataType
+	var mappings []errorMapping
+	if ctx.route != nil {
+		dataType = ctx.route.DataType()
+		mappings = ctx.route.errorMappings
+	}
+	writeMappedError(ctx.RequestCtx, dataType, err, mappings)
+}
+
+// writeError writes the *Error answered for err encoded as dataType, replacing any response body
+func writeError(ctx *fasthttp.RequestCtx, dataType DataType, err error) {
+	writeMappedError(ctx, dataType, err, nil)
+}
+
+// writeMappedError writes the *Error answered for err, checking mappings first. Errors that aren't mapped are
+// logged, as they're answered without their message
+func writeMappedError(ctx *fasthttp.RequestCtx, dataType DataType, err error, mappings []errorMapping) {
+	e, internal := asError(err, mappings)
+	if internal {
+		log.Printf("Internal error serving %s %s: %s", ctx.Method(), ctx.Path(), err)
+	}
+	if dataType != Binary {
+		dataType = JSON
+	}
+	ctx.ResetBody()
+	ctx.SetStatusCode(e.status())
+	b, contentType, encodeErr := encodeBody(dataType, e)
+	if encodeErr != nil {
+		ctx.WriteString(e.Error())
+		return
+	}
+	ctx.SetContentType(string(contentType))
+	ctx.SetBody(b)
+}
+
+// decodeError decodes a response body holding an *Error. It returns nil when the body isn't one
+func decodeError(resp *fasthttp.Response) *Error {
+	dataType := mediaType(resp.Header.ContentType())
+	if dataType != JSON && dataType != Binary {
+		return nil
+	}
+	e := &Error{}
+	if decodeBody(dataType, resp.Body(), e) != nil || e.Code == "" {
+		return nil
+	}
+	e.Status = resp.StatusCode()
+	return e
+}
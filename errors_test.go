@@ -0,0 +1,107 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+var (
+	errItemNotFound = errors.New("item not found")
+	ErrNotFound     = &Error{Code: "not_found", Message: "not found", Status: fasthttp.StatusNotFound}
+
+	FindRouteContract = RouteContract{
+		Label: "Find",
+		Path:  "/find",
+	}
+	RejectRouteContract = RouteContract{
+		Label:             "Reject",
+		Method:            POST,
+		Path:              "/reject",
+		DataHandlerConfig: DataHandlerConfig{BodyType: JSON},
+	}
+	FailRouteContract = RouteContract{
+		Label: "Fail",
+		Path:  "/fail",
+	}
+	MissingRouteContract = RouteContract{
+		Label: "Missing",
+		Path:  "/missing",
+	}
+	ErrorsServiceContract = ServiceContract{
+		Label:           "Errors",
+		Port:            10105,
+		RoutesContracts: []RouteContract{FindRouteContract, RejectRouteContract, FailRouteContract},
+	}
+)
+
+func TestErrors(t *testing.T) {
+	service := Service{ServiceContract: &ErrorsServiceContract}
+	service.MapError(errItemNotFound, ErrNotFound)
+	service.SetupRoute("Find", TypedRoute[struct{}, string](func(ctx *Context, _ struct{}) (string, error) {
+		return "", fmt.Errorf("finding: %w", errItemNotFound)
+	}).Handler(), nil, nil)
+	service.SetupRoute("Reject", func(ctx *Context) {
+		ctx.Error(&Error{Code: "out_of_stock", Message: "no items left", Details: map[string]int{"left": 0}, Status: fasthttp.StatusConflict})
+	}, nil, nil)
+	service.SetupRoute("Fail", func(ctx *Context) {
+		ctx.Error(errors.New("disk full"))
+	}, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	contract := ErrorsServiceContract
+	contract.RoutesContracts = append(contract.RoutesContracts, MissingRouteContract)
+	client := ServiceClient{ServiceContract: &contract}
+
+	err := client.Call(context.Background(), FindRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrNotFound), "Mapped errors must be answered with their mapping")
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr), "Errors must still be returned as a ResponseError")
+	assert.Equal(t, fasthttp.StatusNotFound, respErr.StatusCode)
+	assert.Equal(t, Binary, DataType(respErr.ContentType), "Errors must be encoded with the route DataType")
+	assert.Equal(t, ErrInternal, AsError(errItemNotFound), "Mappings must only apply to the routes of their service")
+
+	err = client.Call(context.Background(), RejectRouteContract, ServiceRequest{}, nil)
+	var lotusErr *Error
+	assert.True(t, errors.As(err, &lotusErr), "Errors answered by a service must be decoded")
+	assert.Equal(t, "out_of_stock", lotusErr.Code)
+	assert.Equal(t, "no items left", lotusErr.Message)
+	assert.Equal(t, fasthttp.StatusConflict, lotusErr.Status, "The error status must be taken from the response")
+	var details map[string]int
+	assert.Nil(t, lotusErr.DecodeDetails(&details))
+	assert.Equal(t, map[string]int{"left": 0}, details, "Error details must be decoded")
+
+	err = client.Call(context.Background(), FailRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrInternal), "Errors not mapped must be answered as internal errors")
+	assert.True(t, errors.As(err, &lotusErr))
+	assert.Equal(t, "internal error", lotusErr.Message, "Errors not mapped must not answer their message")
+	assert.Equal(t, fasthttp.StatusInternalServerError, lotusErr.Status)
+
+	err = client.Call(context.Background(), MissingRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrRouteNotFound), "Requests matching no route must answer ErrRouteNotFound")
+
+	_, err = ErrorsServiceContract.RouteUrl("Missing")
+	assert.True(t, errors.Is(err, ErrRouteNotFound), "Routes missing from a contract must return ErrRouteNotFound")
+}
+
+func TestAsError(t *testing.T) {
+	err := AsError(&ValidationError{Fields: []FieldError{{Field: "Name", Rule: "required"}}})
+	assert.Equal(t, CodeValidationFailed, err.Code)
+	assert.Equal(t, fasthttp.StatusUnprocessableEntity, err.Status)
+	assert.Equal(t, []FieldError{{Field: "Name", Rule: "required"}}, err.Details, "Validation errors must hold the failing fields")
+
+	assert.Equal(t, ErrDeadlineExceeded, AsError(fmt.Errorf("calling: %w", context.DeadlineExceeded)))
+	assert.Equal(t, ErrUnavailable, AsError(&CircuitOpenError{Service: "Users"}), "Open circuits must not answer the services called")
+	assert.Equal(t, ErrBadRequest, AsError(fmt.Errorf("decoding: %w", ErrBadRequest)), "Wrapped errors must be answered as is")
+
+	internal := AsError(errors.New("boom"))
+	assert.Equal(t, ErrInternal, internal, "Errors not mapped must answer the generic ErrInternal")
+	assert.Equal(t, "internal: internal error", ErrInternal.Error(), "Copies must not change the original error")
+
+	assert.True(t, errors.Is(ErrBadRequest.WithMessage("invalid id"), ErrBadRequest), "Errors with the same code must match")
+	assert.False(t, errors.Is(ErrBadRequest, ErrInternal))
+}
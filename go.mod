@@ -1,12 +1,20 @@
 module github.com/brunvieira/lotus
 
-go 1.14
+go 1.18
 
 require (
 	github.com/brunvieira/fastalice v0.0.0-20201015203900-6c4dea19d447
 	github.com/buaazp/fasthttprouter v0.1.1
-	github.com/mitchellh/mapstructure v1.3.3
 	github.com/stretchr/testify v1.6.1
 	github.com/valyala/fasthttp v1.16.0
 	github.com/vmihailenco/msgpack v4.0.4+incompatible
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.0 // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/klauspost/compress v1.11.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
 )
@@ -0,0 +1,117 @@
+package lotus
+
+import (
+	"fmt"
+	"github.com/brunvieira/fastalice"
+	"strings"
+)
+
+// RouteGroup shares a middleware stack between the routes whose contract Path is under its Prefix, e.g. the "/admin"
+// group applies to "/admin" and "/admin/users" but not to "/administrators". Groups apply to every matching route of
+// the service, however it was set up. Nested groups run after their parent
+type RouteGroup struct {
+	// Prefix is the path prefix of the routes of the group, including the prefix of its parent
+	Prefix string
+	// Middlewares are executed, in order, before the middlewares of nested groups and routes
+	Middlewares []fastalice.Constructor
+	// ContextMiddlewares are executed, in order, before the ContextMiddlewares of nested groups and routes
+	ContextMiddlewares []Middleware
+
+	service *Service
+	groups  []*RouteGroup
+}
+
+// Use adds middlewares to the service. They are executed, in order, on every route. The chain of a route is: panic
+// recovery, service middlewares, group middlewares from the outermost group, route middlewares, route DataHandler,
+// then the context middlewares in the same order and the RequestHandler. Middlewares must be added before the service
+// starts
+func (service *Service) Use(middlewares ...fastalice.Constructor) {
+	service.middlewares = append(service.middlewares, middlewares...)
+}
+
+// UseContext adds context middlewares to the service. They are executed, in order, on every route once its payload
+// is decoded
+func (service *Service) UseContext(middlewares ...Middleware) {
+	service.contextMiddlewares = append(service.contextMiddlewares, middlewares...)
+}
+
+// Group returns a group for the routes under prefix executing middlewares
+func (service *Service) Group(prefix string, middlewares ...fastalice.Constructor) *RouteGroup {
+	group := &RouteGroup{Prefix: cleanPrefix(prefix), Middlewares: middlewares, service: service}
+	service.groups = append(service.groups, group)
+	return group
+}
+
+// Group returns a nested group for the routes under the group Prefix followed by prefix
+func (group *RouteGroup) Group(prefix string, middlewares ...fastalice.Constructor) *RouteGroup {
+	nested := &RouteGroup{Prefix: group.Prefix + cleanPrefix(prefix), Middlewares: middlewares, service: group.service}
+	group.groups = append(group.groups, nested)
+	return nested
+}
+
+// Use adds middlewares to the group
+func (group *RouteGroup) Use(middlewares ...fastalice.Constructor) {
+	group.Middlewares = append(group.Middlewares, middlewares...)
+}
+
+// UseContext adds context middlewares to the group
+func (group *RouteGroup) UseContext(middlewares ...Middleware) {
+	group.ContextMiddlewares = append(group.ContextMiddlewares, middlewares...)
+}
+
+// SetupRoute sets up a route of the service like Service.SetupRoute. Routes whose contract Path isn't under the group
+// Prefix are reported as an error by Start
+func (group *RouteGroup) SetupRoute(
+	label string,
+	endpoint RequestHandler,
+	middlewares []fastalice.Constructor,
+	dataHandler fastalice.Constructor,
+) *Route {
+	routeContract := group.service.routeContract(label)
+	if routeContract != nil && !group.matches(routeContract.Path) {
+		group.service.setupErrors = append(group.service.setupErrors,
+			fmt.Errorf("route %s path %s is not under the group prefix %s", label, routeContract.Path, group.Prefix))
+		return nil
+	}
+	return group.service.SetupRoute(label, endpoint, middlewares, dataHandler)
+}
+
+// matches reports whether path is under the group Prefix
+func (group *RouteGroup) matches(path string) bool {
+	if !strings.HasPrefix(path, group.Prefix) {
+		return false
+	}
+	rest := path[len(group.Prefix):]
+	return rest == "" || rest[0] == '/' || group.Prefix == ""
+}
+
+// appendMatching appends the groups matching path, each one followed by its matching nested groups
+func appendMatching(matching []*RouteGroup, groups []*RouteGroup, path string) []*RouteGroup {
+	for _, group := range groups {
+		if group.matches(path) {
+			matching = append(matching, group)
+			matching = appendMatching(matching, group.groups, path)
+		}
+	}
+	return matching
+}
+
+// routeMiddlewares returns the service and group middlewares executed before the route ones
+func (service *Service) routeMiddlewares(route *Route) ([]fastalice.Constructor, []Middleware) {
+	middlewares := append([]fastalice.Constructor(nil), service.middlewares...)
+	contextMiddlewares := append([]Middleware(nil), service.contextMiddlewares...)
+	for _, group := range appendMatching(nil, service.groups, route.Path) {
+		middlewares = append(middlewares, group.Middlewares...)
+		contextMiddlewares = append(contextMiddlewares, group.ContextMiddlewares...)
+	}
+	return middlewares, contextMiddlewares
+}
+
+// cleanPrefix returns prefix with a leading slash and without trailing slashes
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
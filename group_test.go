@@ -0,0 +1,97 @@
+package lotus
+
+import (
+	"context"
+	"github.com/brunvieira/fastalice"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"testing"
+)
+
+var (
+	PublicRouteContract = RouteContract{
+		Label: "Public",
+		Path:  "/public",
+	}
+	AdminUsersRouteContract = RouteContract{
+		Label: "AdminUsers",
+		Path:  "/admin/users",
+	}
+	AdminAuditRouteContract = RouteContract{
+		Label: "AdminAudit",
+		Path:  "/admin/audit",
+	}
+	AdministratorsRouteContract = RouteContract{
+		Label: "Administrators",
+		Path:  "/administrators",
+	}
+	GroupsServiceContract = ServiceContract{
+		Label: "Groups",
+		Port:  10107,
+		RoutesContracts: []RouteContract{
+			PublicRouteContract,
+			AdminUsersRouteContract,
+			AdminAuditRouteContract,
+			AdministratorsRouteContract,
+		},
+	}
+)
+
+// tracing returns a middleware appending name to the request trace
+func tracing(name string) fastalice.Constructor {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			trace, _ := ctx.UserValue("trace").(string)
+			ctx.SetUserValue("trace", trace+name+",")
+			next(ctx)
+		}
+	}
+}
+
+func writeTrace(ctx *Context) {
+	trace, _ := ctx.UserValue("trace").(string)
+	ctx.WriteString(trace)
+}
+
+func TestRouteGroups(t *testing.T) {
+	service := Service{ServiceContract: &GroupsServiceContract}
+	service.Use(tracing("service"))
+	admin := service.Group("/admin/", tracing("admin"))
+	users := admin.Group("users", tracing("users"))
+	admin.Use(tracing("admin2"))
+
+	service.SetupRoute("Public", writeTrace, []fastalice.Constructor{tracing("route")}, nil)
+	users.SetupRoute("AdminUsers", writeTrace, []fastalice.Constructor{tracing("route")}, tracing("data"))
+	service.SetupRoute("AdminAudit", writeTrace, nil, nil)
+	service.SetupRoute("Administrators", writeTrace, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	assert.Equal(t, "/admin", admin.Prefix, "Group prefixes must be cleaned")
+	assert.Equal(t, "/admin/users", users.Prefix, "Nested groups must be under their parent prefix")
+
+	client := ServiceClient{ServiceContract: &GroupsServiceContract}
+	for _, tc := range []struct {
+		route    RouteContract
+		expected string
+		message  string
+	}{
+		{PublicRouteContract, "service,route,", "Service middlewares must run before route middlewares"},
+		{AdminUsersRouteContract, "service,admin,admin2,users,route,data,", "Group middlewares must run from the outermost group, before the route chain"},
+		{AdminAuditRouteContract, "service,admin,admin2,", "Groups must apply to routes set up on the service"},
+		{AdministratorsRouteContract, "service,", "Groups must only match whole path segments"},
+	} {
+		var trace string
+		err := client.Call(context.Background(), tc.route, ServiceRequest{}, &trace)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, trace, tc.message)
+	}
+}
+
+func TestRouteGroupSetupErrors(t *testing.T) {
+	service := Service{ServiceContract: &GroupsServiceContract}
+	route := service.Group("/admin").SetupRoute("Public", writeTrace, nil, nil)
+	assert.Nil(t, route, "Routes outside the group prefix must not be set up")
+	assert.Len(t, service.setupErrors, 1, "Routes outside the group prefix must be reported")
+	assert.Contains(t, service.setupErrors[0].Error(), "not under the group prefix /admin")
+}
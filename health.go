@@ -0,0 +1,161 @@
+package lotus
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHealthCheckInterval is the interval HealthCheck probes the instances of a service
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultHealthCheckTimeout is the time HealthCheck waits for the answer of a probe
+	DefaultHealthCheckTimeout = 2 * time.Second
+	// DefaultHealthCheckThreshold is the number of consecutive failures that eject an instance
+	DefaultHealthCheckThreshold = 3
+)
+
+// HealthCheck ejects the instances of a service that fail and adds them back once they recover. A request or probe
+// fails on a transport error or a 5xx status. Instances are ejected after Threshold consecutive failures and added
+// back after a successful request or probe. While ServiceClient.RunHealthCheck runs, every instance is probed each
+// Interval with a GET on Path. Services run it for the clients of their subscriptions. When every instance is ejected
+// requests are sent to all of them. The zero value is ready to use
+type HealthCheck struct {
+	// Path is probed on each instance, relative to the service Suffix. Any status below 500 is healthy, so the
+	// service root is probed by default
+	Path string
+	// Interval is the time between probes. Defaults to DefaultHealthCheckInterval
+	Interval time.Duration
+	// Timeout bounds each probe. Defaults to DefaultHealthCheckTimeout
+	Timeout time.Duration
+	// Threshold is the number of consecutive failures that eject an instance. Defaults to DefaultHealthCheckThreshold
+	Threshold int
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// Healthy reports whether the instance at address is not ejected
+func (hc *HealthCheck) Healthy(address string) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.failures[address] < hc.threshold()
+}
+
+// available returns the instances that are not ejected, or every instance when all of them are
+func (hc *HealthCheck) available(addresses []string) []string {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	healthy := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if hc.failures[address] < hc.threshold() {
+			healthy = append(healthy, address)
+		}
+	}
+	if len(healthy) == 0 {
+		return addresses
+	}
+	return healthy
+}
+
+// report records the outcome of a request or probe sent to the instance at address
+func (hc *HealthCheck) report(address string, healthy bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if healthy {
+		delete(hc.failures, address)
+		return
+	}
+	if hc.failures == nil {
+		hc.failures = map[string]int{}
+	}
+	hc.failures[address]++
+}
+
+// RunHealthCheck probes the instances of the service each HealthCheck Interval until ctx is done. It returns right
+// away when the client has no HealthCheck or Resolver. Without it, instances are only ejected and added back by the
+// outcome of requests
+func (sc *ServiceClient) RunHealthCheck(ctx context.Context) {
+	hc := sc.HealthCheck
+	if hc == nil || sc.Resolver == nil {
+		return
+	}
+	ticker := time.NewTicker(hc.interval())
+	defer ticker.Stop()
+	for {
+		hc.check(ctx, sc)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check probes every resolved instance. Instances no longer resolved are forgotten
+func (hc *HealthCheck) check(ctx context.Context, sc *ServiceClient) {
+	addresses, err := sc.Resolver.Resolve(ctx, sc.ServiceContract)
+	if err != nil {
+		return
+	}
+
+	resolved := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		resolved[address] = true
+	}
+	hc.mu.Lock()
+	for address := range hc.failures {
+		if !resolved[address] {
+			delete(hc.failures, address)
+		}
+	}
+	hc.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			hc.report(address, hc.probe(sc, address))
+		}(address)
+	}
+	wg.Wait()
+}
+
+// probe reports whether the instance at address answers Path. Probes skip the client Pool, so they aren't counted by
+// its status
+func (hc *HealthCheck) probe(sc *ServiceClient, address string) bool {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(sc.protocol() + "://" + address + sc.Suffix() + hc.Path)
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	err := sc.doUnpooled(req, resp, time.Now().Add(timeout))
+	return healthyResponse(err, resp.StatusCode())
+}
+
+func (hc *HealthCheck) threshold() int {
+	if hc.Threshold <= 0 {
+		return DefaultHealthCheckThreshold
+	}
+	return hc.Threshold
+}
+
+func (hc *HealthCheck) interval() time.Duration {
+	if hc.Interval <= 0 {
+		return DefaultHealthCheckInterval
+	}
+	return hc.Interval
+}
+
+// healthyResponse reports whether a request reached an instance able to answer it
+func healthyResponse(err error, status int) bool {
+	return err == nil && status < fasthttp.StatusInternalServerError
+}
@@ -0,0 +1,37 @@
+package lotus
+
+import (
+	"github.com/brunvieira/fastalice"
+	"github.com/valyala/fasthttp"
+)
+
+// Middleware wraps a RequestHandler. Middlewares run once the payload is decoded, so they can read or replace it
+// with Payload and SetPayload, use the ServiceClients, or answer with Context.Error without calling next
+type Middleware func(next RequestHandler) RequestHandler
+
+// Adapt converts a fastalice.Constructor into a Middleware, so existing middlewares can run after the payload is
+// decoded. The constructor is applied on each request
+func Adapt(constructor fastalice.Constructor) Middleware {
+	return func(next RequestHandler) RequestHandler {
+		return func(ctx *Context) {
+			constructor(func(*fasthttp.RequestCtx) {
+				next(ctx)
+			})(ctx.RequestCtx)
+		}
+	}
+}
+
+// With adds context middlewares to the route. It returns the route, so it can follow SetupRoute, which returns nil
+// for routes missing from the contract
+func (route *Route) With(middlewares ...Middleware) *Route {
+	if route == nil {
+		return nil
+	}
+	route.ContextMiddlewares = append(route.ContextMiddlewares, middlewares...)
+	return route
+}
+
+// SetPayload replaces the payload decoded by the route DataHandler. Handlers read it with Payload
+func (ctx *Context) SetPayload(v interface{}) {
+	ctx.SetUserValue(ctx.userValueKey(), v)
+}
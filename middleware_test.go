@@ -0,0 +1,102 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"strings"
+	"testing"
+)
+
+type Greeting struct {
+	Name string
+}
+
+var (
+	ErrUnauthorized = &Error{Code: "unauthorized", Message: "missing token", Status: fasthttp.StatusUnauthorized}
+
+	GreetRouteContract = RouteContract{
+		Label:             "Greet",
+		Method:            POST,
+		Path:              "/private/greet",
+		DataHandlerConfig: DataHandlerConfig{BodyType: JSON},
+		Data:              Greeting{},
+	}
+	MiddlewaresServiceContract = ServiceContract{
+		Label:           "Middlewares",
+		Port:            10108,
+		RoutesContracts: []RouteContract{GreetRouteContract},
+	}
+)
+
+// tracingContext returns a context middleware appending name to the request trace
+func tracingContext(name string) Middleware {
+	return func(next RequestHandler) RequestHandler {
+		return func(ctx *Context) {
+			trace, _ := ctx.UserValue("trace").(string)
+			ctx.SetUserValue("trace", trace+name+",")
+			next(ctx)
+		}
+	}
+}
+
+func TestContextMiddlewares(t *testing.T) {
+	service := Service{ServiceContract: &MiddlewaresServiceContract}
+	service.SubscribeToService(MiddlewaresServiceContract)
+	service.Use(tracing("service"))
+	service.UseContext(tracingContext("service-context"))
+	private := service.Group("/private", tracing("group"))
+	private.UseContext(func(next RequestHandler) RequestHandler {
+		return func(ctx *Context) {
+			if len(ctx.Request.Header.Peek("Authorization")) == 0 {
+				ctx.Error(ErrUnauthorized)
+				return
+			}
+			next(ctx)
+		}
+	})
+
+	private.SetupRoute("Greet", func(ctx *Context) {
+		greeting, _ := Payload[Greeting](ctx)
+		trace, _ := ctx.UserValue("trace").(string)
+		ctx.WriteString(trace + "hello " + greeting.Name)
+	}, nil, nil).With(
+		func(next RequestHandler) RequestHandler {
+			return func(ctx *Context) {
+				greeting, err := Payload[Greeting](ctx)
+				if err != nil || ctx.ServiceClient(MiddlewaresServiceContract) == nil {
+					ctx.Error(errors.New("payload and clients must be available"))
+					return
+				}
+				greeting.Name = strings.ToUpper(greeting.Name)
+				ctx.SetPayload(greeting)
+				next(ctx)
+			}
+		},
+		Adapt(tracing("adapted")),
+	)
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &MiddlewaresServiceContract}
+	err := client.Call(context.Background(), GreetRouteContract, ServiceRequest{Body: Greeting{Name: "lotus"}}, nil)
+	assert.True(t, errors.Is(err, ErrUnauthorized), "Context middlewares must be able to answer structured errors")
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	url, _ := MiddlewaresServiceContract.RouteUrl("Greet")
+	req.SetRequestURI(url)
+	assert.Nil(t, GreetRouteContract.prepareRequest(req, ServiceRequest{Body: Greeting{Name: "lotus"}}))
+	req.Header.Set("Authorization", "Bearer token")
+	assert.Nil(t, fasthttp.Do(req, resp))
+	assert.Equal(t, "service,group,service-context,adapted,hello LOTUS", string(resp.Body()),
+		"Context middlewares must run after the DataHandler and be able to replace the payload")
+}
+
+func TestRouteWith(t *testing.T) {
+	var route *Route
+	assert.Nil(t, route.With(tracingContext("ignored")), "With must accept routes that failed to be set up")
+}
@@ -0,0 +1,188 @@
+package lotus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"reflect"
+)
+
+// DefaultMaxMultipartMemory is the amount of bytes of multipart file parts kept in memory when decoding a request.
+// File parts exceeding it are stored in temporary files
+const DefaultMaxMultipartMemory int64 = 32 << 20
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	bytesType           = reflect.TypeOf([]byte(nil))
+	readerType          = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// encodeMultipart encodes the fields of data as a multipart form. []byte, io.Reader and *multipart.FileHeader
+// fields are written as file parts named after the field, every other field is written as a form value
+func encodeMultipart(data interface{}) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	err := eachField(data, func(k string, v reflect.Value) error {
+		return writeMultipartField(w, k, v)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func eachField(data interface{}, f func(k string, v reflect.Value) error) error {
+	if data == nil {
+		return nil
+	}
+	if m, ok := data.(map[string]interface{}); ok {
+		for k, v := range m {
+			if err := f(k, reflect.ValueOf(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unable to encode %T as a multipart form", data)
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if err := f(field.Name, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMultipartField(w *multipart.Writer, k string, v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	switch {
+	case v.Type() == bytesType:
+		return writeMultipartFile(w, k, k, bytes.NewReader(v.Bytes()))
+	case v.Type() == fileHeaderType:
+		if v.IsNil() {
+			return nil
+		}
+		return writeMultipartFileHeader(w, k, v.Interface().(*multipart.FileHeader))
+	case v.Type() == fileHeaderSliceType:
+		for _, fh := range v.Interface().([]*multipart.FileHeader) {
+			if err := writeMultipartFileHeader(w, k, fh); err != nil {
+				return err
+			}
+		}
+		return nil
+	case v.Type().Implements(readerType):
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+		}
+		return writeMultipartFile(w, k, k, v.Interface().(io.Reader))
+	case v.Kind() == reflect.Interface:
+		return writeMultipartField(w, k, v.Elem())
+	}
+
+	form := map[string][]string{}
+	valuesToForm(form, k, v)
+	for _, value := range form[k] {
+		if err := w.WriteField(k, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMultipartFileHeader(w *multipart.Writer, k string, fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeMultipartFile(w, k, fh.Filename, f)
+}
+
+func writeMultipartFile(w *multipart.Writer, k, filename string, r io.Reader) error {
+	part, err := w.CreateFormFile(k, filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, r)
+	return err
+}
+
+// decodeMultipart reads a multipart body and decodes it into the value pointed by out. The returned form must be
+// removed once the request is served
+func decodeMultipart(body, boundary []byte, maxMemory int64, out interface{}) (*multipart.Form, error) {
+	if len(boundary) == 0 {
+		return nil, fmt.Errorf("missing multipart boundary")
+	}
+	form, err := multipart.NewReader(bytes.NewReader(body), string(boundary)).ReadForm(maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	return form, multipartToData(form, out)
+}
+
+// multipartToData fills out with the form values and files. File parts are set on *multipart.FileHeader,
+// []*multipart.FileHeader and []byte fields
+func multipartToData(form *multipart.Form, out interface{}) error {
+	if err := urlValuesToData(form.Value, out); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(out).Elem()
+	if v.Kind() == reflect.Map {
+		for k, files := range form.File {
+			if len(files) == 1 {
+				v.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(files[0]))
+			} else {
+				v.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(files))
+			}
+		}
+		return nil
+	}
+
+	typ := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := typ.Field(i)
+		files, ok := form.File[field.Name]
+		if !ok || len(files) == 0 || field.PkgPath != "" {
+			continue
+		}
+		switch field.Type {
+		case fileHeaderType:
+			v.Field(i).Set(reflect.ValueOf(files[0]))
+		case fileHeaderSliceType:
+			v.Field(i).Set(reflect.ValueOf(files))
+		case bytesType:
+			b, err := readFileHeader(files[0])
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			v.Field(i).SetBytes(b)
+		}
+	}
+	return nil
+}
+
+func readFileHeader(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
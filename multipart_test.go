@@ -0,0 +1,141 @@
+package lotus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"mime/multipart"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type UploadRequest struct {
+	Name     string
+	Tags     []string
+	Avatar   []byte
+	Document *multipart.FileHeader
+}
+
+type UploadClientRequest struct {
+	Name     string
+	Tags     []string
+	Avatar   []byte
+	Document *strings.Reader
+}
+
+func uploadHandler(ctx *Context) {
+	upload, err := Payload[UploadRequest](ctx)
+	if err != nil || upload.Document == nil {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		return
+	}
+	document, err := readFileHeader(upload.Document)
+	if err != nil {
+		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.WriteString(fmt.Sprintf("%s%v[%s][%s:%s]", upload.Name, upload.Tags, upload.Avatar, upload.Document.Filename, document))
+}
+
+func TestMultipartForm(t *testing.T) {
+	path := "/upload"
+	contract := &RouteContract{
+		Label:  "Upload",
+		Method: POST,
+		Path:   path,
+		DataHandlerConfig: DataHandlerConfig{
+			BodyType:    MultipartForm,
+			MaxBodySize: 1024,
+		},
+		Data: UploadRequest{},
+	}
+	route := &Route{RouteContract: contract, RequestHandler: uploadHandler}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10083"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{Body: UploadClientRequest{
+		Name:     "lotus",
+		Tags:     []string{"foo", "bar"},
+		Avatar:   []byte("avatar"),
+		Document: strings.NewReader("document"),
+	}})
+	assert.Nil(t, err, "Encoding a multipart request must not return an error")
+	assert.True(t, strings.HasPrefix(string(req.Header.ContentType()), string(MultipartForm)), "Request must have a multipart content type")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusOK, resp.StatusCode(), "Multipart request must be decoded")
+	assert.Equal(t, "lotus[foo bar][avatar][Document:document]", string(resp.Body()), "Multipart values and files must be decoded")
+
+	err = contract.prepareRequest(req, ServiceRequest{Body: map[string]interface{}{
+		"Avatar": make([]byte, 2048),
+	}})
+	assert.Nil(t, err, "Encoding a multipart map must not return an error")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusRequestEntityTooLarge, resp.StatusCode(), "Bodies over the route limit must be rejected")
+}
+
+func TestBodyLimitsBeforeReading(t *testing.T) {
+	small := RouteContract{
+		Label:             "SmallUpload",
+		Method:            POST,
+		Path:              "/uploads/:kind/small",
+		DataHandlerConfig: DataHandlerConfig{BodyType: MultipartForm, MaxBodySize: 1024},
+		Data:              UploadRequest{},
+	}
+	large := small
+	large.Label = "LargeUpload"
+	large.Path = "/uploads/:kind/large"
+	large.DataHandlerConfig.MaxBodySize = 2 * fasthttp.DefaultMaxRequestBodySize
+	contract := ServiceContract{Label: "Uploads", Port: 10109, RoutesContracts: []RouteContract{small, large}}
+	service := Service{ServiceContract: &contract}
+	service.SetupRoute("SmallUpload", uploadHandler, nil, nil)
+	service.SetupRoute("LargeUpload", uploadHandler, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:10109")
+	assert.Nil(t, err)
+	defer conn.Close()
+	fmt.Fprint(conn, "POST "+contract.Suffix()+"/uploads/files/small HTTP/1.1\r\nHost: localhost\r\nContent-Type: multipart/form-data; boundary=x\r\nContent-Length: 1000000\r\n\r\n")
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	err = resp.Read(bufio.NewReader(conn))
+	assert.Nil(t, err, "Bodies over the route limit must be rejected before they are sent")
+	assert.Equal(t, fasthttp.StatusRequestEntityTooLarge, resp.StatusCode())
+	assert.True(t, errors.Is(decodeError(resp), ErrPayloadTooLarge), "Bodies over the route limit must be answered with ErrPayloadTooLarge")
+
+	// a pool of its own, as the default client may keep a connection to the service of a previous run
+	client := ServiceClient{ServiceContract: &contract, Pool: &ClientPool{}}
+	err = client.Call(context.Background(), large, ServiceRequest{
+		RouteParams: map[string]string{"kind": "files"},
+		Body: UploadClientRequest{
+			Name:     "lotus",
+			Avatar:   make([]byte, fasthttp.DefaultMaxRequestBodySize+1),
+			Document: strings.NewReader("document"),
+		},
+	}, nil)
+	assert.Nil(t, err, "Route limits must be able to raise the server one")
+}
@@ -0,0 +1,275 @@
+package lotus
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the generated documents
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDocument is an OpenAPI 3 document describing a service
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi" yaml:"openapi"`
+	Info       OpenAPIInfo                `json:"info" yaml:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths" yaml:"paths"`
+	Components *OpenAPIComponents         `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+// OpenAPIPathItem maps the lower case methods of a path to their operations
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId" yaml:"operationId"`
+	Summary     string                      `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                      `json:"description,omitempty" yaml:"description,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses" yaml:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema" yaml:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content" yaml:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description" yaml:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// OpenAPI generates an OpenAPI 3 document for every service of the contract, indexed by the service Label
+func (c *Contract) OpenAPI() map[string]*OpenAPIDocument {
+	docs := make(map[string]*OpenAPIDocument, len(c.Services))
+	for i := range c.Services {
+		docs[c.Services[i].Label] = c.Services[i].OpenAPI()
+	}
+	return docs
+}
+
+// OpenAPI generates an OpenAPI 3 document describing the routes of the service. Data fields are documented as
+// query parameters on methods without body and as the request body, encoded with the route BodyType, otherwise
+func (sc *ServiceContract) OpenAPI() *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       sc.Label,
+			Description: sc.Description,
+			Version:     sc.version(),
+		},
+		Servers: []OpenAPIServer{{URL: sc.protocol() + "://" + sc.address()}},
+		Paths:   map[string]OpenAPIPathItem{},
+	}
+	definitions := map[string]*Schema{}
+
+	for i := range sc.RoutesContracts {
+		route := &sc.RoutesContracts[i]
+		path := openAPIPath(sc.Suffix() + route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = OpenAPIPathItem{}
+			doc.Paths[path] = item
+		}
+
+		schema, defs := route.schema()
+		for name, def := range defs {
+			definitions[name] = def
+		}
+		validated := schema.hasValidation(defs)
+		for _, method := range route.openAPIMethods() {
+			key := strings.ToLower(string(method))
+			if _, exists := item[key]; exists {
+				continue
+			}
+			item[key] = route.openAPIOperation(method, schema, validated)
+		}
+	}
+
+	if len(definitions) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: definitions}
+	}
+	return doc
+}
+
+// openAPIMethods returns the methods documented for the route. ANY routes are documented on every method
+// supported by OpenAPI and GET routes also document their implicit HEAD route
+func (route *RouteContract) openAPIMethods() []Method {
+	switch route.method() {
+	case ANY:
+		return []Method{GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS, TRACE}
+	case CONNECT:
+		return nil
+	case GET:
+		return []Method{GET, HEAD}
+	}
+	return []Method{route.method()}
+}
+
+func (route *RouteContract) openAPIOperation(method Method, schema *Schema, validated bool) *OpenAPIOperation {
+	operation := &OpenAPIOperation{
+		OperationID: route.Label,
+		Summary:     route.Label,
+		Description: route.Description,
+		Responses: map[string]*OpenAPIResponse{
+			"200": {Description: http.StatusText(http.StatusOK)},
+		},
+	}
+	if route.method() == ANY || (route.method() == GET && method == HEAD) {
+		name := strings.ToLower(string(method))
+		operation.OperationID += strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	body := schema
+	if body != nil && body.Type == "object" {
+		body = withoutPathParams(body)
+	}
+	for _, param := range route.routeParams() {
+		operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+			Name:     param,
+			In:       "path",
+			Required: true,
+			Schema:   pathParamSchema(schema, param),
+		})
+	}
+
+	if body == nil {
+		return operation
+	}
+	operation.Responses["400"] = &OpenAPIResponse{
+		Description: http.StatusText(http.StatusBadRequest),
+		Content:     route.errorContent(errorSchema(nil)),
+	}
+	if validated {
+		fields, _ := SchemaOf([]FieldError{}, JSON)
+		operation.Responses["422"] = &OpenAPIResponse{
+			Description: http.StatusText(http.StatusUnprocessableEntity),
+			Content:     route.errorContent(errorSchema(fields)),
+		}
+	}
+
+	if !hasBody(method) {
+		operation.Parameters = append(operation.Parameters, queryParameters(body)...)
+		return operation
+	}
+	operation.RequestBody = &OpenAPIRequestBody{
+		Required: len(body.Required) > 0,
+		Content:  route.openAPIContent(body),
+	}
+	return operation
+}
+
+// openAPIContent returns the content of a payload encoded with the route DataType
+func (route *RouteContract) openAPIContent(schema *Schema) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{string(route.DataType()): {Schema: schema}}
+}
+
+// errorContent documents an Error answered by the route. Errors of form routes are answered with JSON
+func (route *RouteContract) errorContent(schema *Schema) map[string]OpenAPIMediaType {
+	dataType := route.DataType()
+	if dataType != Binary {
+		dataType = JSON
+	}
+	return map[string]OpenAPIMediaType{string(dataType): {Schema: schema}}
+}
+
+// hasBody reports whether requests of method are documented with a body
+func hasBody(method Method) bool {
+	switch method {
+	case GET, HEAD, DELETE, OPTIONS, TRACE:
+		return false
+	}
+	return true
+}
+
+// withoutPathParams returns a copy of an object schema without the properties bound to route params
+func withoutPathParams(schema *Schema) *Schema {
+	result := *schema
+	result.Properties = map[string]*Schema{}
+	result.PropertyOrder = nil
+	result.Required = nil
+	for _, name := range schema.PropertyOrder {
+		if schema.Properties[name].PathParam != "" {
+			continue
+		}
+		result.Properties[name] = schema.Properties[name]
+		result.PropertyOrder = append(result.PropertyOrder, name)
+	}
+	for _, name := range schema.Required {
+		if _, ok := result.Properties[name]; ok {
+			result.Required = append(result.Required, name)
+		}
+	}
+	return &result
+}
+
+// pathParamSchema returns the schema of the Data property bound to param, defaulting to a string
+func pathParamSchema(schema *Schema, param string) *Schema {
+	if schema != nil {
+		for _, name := range schema.PropertyOrder {
+			if property := schema.Properties[name]; property.PathParam == param {
+				s := *property
+				s.PathParam = ""
+				return &s
+			}
+		}
+	}
+	return &Schema{Type: "string"}
+}
+
+// queryParameters documents the properties of an object schema as query parameters
+func queryParameters(schema *Schema) []OpenAPIParameter {
+	var params []OpenAPIParameter
+	for _, name := range schema.PropertyOrder {
+		params = append(params, OpenAPIParameter{
+			Name:     name,
+			In:       "query",
+			Required: contains(schema.Required, name),
+			Schema:   schema.Properties[name],
+		})
+	}
+	return params
+}
+
+// errorSchema returns the schema of an Error, with the given schema for its details
+func errorSchema(details *Schema) *Schema {
+	s, _ := SchemaOf(Error{}, JSON)
+	if details != nil {
+		s.Properties["details"] = details
+	}
+	return s
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package lotus
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type TreeNode struct {
+	Name     string `json:"name"`
+	Children []TreeNode
+	internal string
+}
+
+var OpenAPIContract = Contract{
+	Services: []ServiceContract{
+		{
+			Label:       "Users",
+			Description: "Manages users",
+			Namespace:   "api",
+			Port:        10092,
+			Version:     "v1",
+			RoutesContracts: []RouteContract{
+				{
+					Label:       "GetUser",
+					Description: "Returns a user",
+					Path:        "/users/:id/:slot",
+					Data:        UserParams{},
+				},
+				{
+					Label:             "SignUp",
+					Method:            POST,
+					Path:              "/users",
+					DataHandlerConfig: DataHandlerConfig{BodyType: JSON},
+					Data:              SignUp{},
+				},
+				{
+					Label:  "Tree",
+					Method: PUT,
+					Path:   "/tree",
+					Data:   TreeNode{},
+				},
+			},
+		},
+	},
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, definitions := SchemaOf(SignUp{}, JSON)
+	assert.Nil(t, definitions, "A non recursive type must not return definitions")
+	assert.Equal(t, "object", schema.Type, "Structs must be documented as objects")
+	assert.Equal(t, "SignUp", schema.Title, "The title must be the type name")
+	assert.Equal(t, []string{"Name", "Age", "Plan", "Tags", "Address", "Secondary", "Contacts"}, schema.PropertyOrder, "Properties must keep the fields order")
+	assert.Equal(t, []string{"Name"}, schema.Required, "Required fields must be listed")
+
+	name := schema.Properties["Name"]
+	assert.Equal(t, "string", name.Type)
+	assert.Equal(t, 2, *name.MinLength, "String min rules must be documented as minLength")
+	assert.Equal(t, 10, *name.MaxLength, "String max rules must be documented as maxLength")
+	assert.Equal(t, 18.0, *schema.Properties["Age"].Minimum, "Number min rules must be documented as minimum")
+	assert.Equal(t, []string{"free", "pro"}, schema.Properties["Plan"].Enum, "Enum rules must be documented")
+	assert.Equal(t, 2, *schema.Properties["Tags"].MaxItems, "Slice max rules must be documented as maxItems")
+	assert.Equal(t, "^[0-9]+$", schema.Properties["Address"].Properties["Zip"].Pattern, "Nested structs must be documented")
+	assert.True(t, schema.Properties["Secondary"].Nullable, "Pointers must be nullable")
+	assert.Equal(t, "Address", schema.Properties["Contacts"].Items.Title, "Slice items must be documented")
+
+	schema, definitions = SchemaOf(TreeNode{}, JSON)
+	assert.Equal(t, "#/components/schemas/TreeNode", schema.Ref, "Recursive types must be referenced")
+	assert.Equal(t, []string{"name", "Children"}, definitions["TreeNode"].PropertyOrder, "Json names must be used and unexported fields skipped")
+	assert.Equal(t, "#/components/schemas/TreeNode", definitions["TreeNode"].Properties["Children"].Items.Ref, "Recursive fields must be referenced")
+}
+
+type TaggedItem struct {
+	Sku   string `json:"sku"`
+	Price int    `msgpack:"price"`
+	Notes string `json:"-" msgpack:"-"`
+}
+
+func TestSchemaFieldNames(t *testing.T) {
+	for _, dataType := range []DataType{JSON, Binary, Form} {
+		schema, _ := SchemaOf(TaggedItem{}, dataType)
+		body, _, err := encodeBody(dataType, TaggedItem{Sku: "A1", Price: 2, Notes: "fragile"})
+		assert.Nil(t, err)
+		encoded := map[string]interface{}{}
+		assert.Nil(t, decodeBody(dataType, body, &encoded))
+		names := make([]string, 0, len(encoded))
+		for name := range encoded {
+			names = append(names, name)
+		}
+		assert.ElementsMatch(t, names, schema.PropertyOrder, "Properties must be named like the %s codec encodes them", dataType)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	docs := OpenAPIContract.OpenAPI()
+	doc := docs["Users"]
+	assert.NotNil(t, doc, "A document must be generated for every service")
+	assert.Equal(t, "Users", doc.Info.Title)
+	assert.Equal(t, "v1", doc.Info.Version)
+	assert.Equal(t, "http://localhost:10092", doc.Servers[0].URL)
+
+	getUser := doc.Paths["/api/v1/users/{id}/{slot}"]["get"]
+	assert.NotNil(t, getUser, "Paths must use the service suffix and the OpenAPI param notation")
+	assert.Equal(t, "GetUser", getUser.OperationID)
+	assert.Equal(t, "Returns a user", getUser.Description)
+	assert.Nil(t, getUser.RequestBody, "GET routes must not document a request body")
+	assert.Equal(t, []OpenAPIParameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+		{Name: "slot", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "Name", In: "query", Schema: &Schema{Type: "string"}},
+	}, getUser.Parameters, "Path params must be typed from Data and the remaining fields documented as query params")
+	assert.Equal(t, "GetUserHead", doc.Paths["/api/v1/users/{id}/{slot}"]["head"].OperationID, "Implicit HEAD routes must be documented")
+
+	signUp := doc.Paths["/api/v1/users"]["post"]
+	assert.NotNil(t, signUp.RequestBody, "POST routes must document a request body")
+	assert.True(t, signUp.RequestBody.Required, "Bodies with required fields must be required")
+	assert.Equal(t, "SignUp", signUp.RequestBody.Content["application/json"].Schema.Title, "The content type must be the route BodyType")
+	assert.NotNil(t, signUp.Responses["422"], "Validated routes must document validation errors")
+
+	tree := doc.Paths["/api/v1/tree"]["put"]
+	assert.Equal(t, "#/components/schemas/TreeNode", tree.RequestBody.Content["application/msgpack"].Schema.Ref, "The default BodyType must be used")
+	assert.Contains(t, doc.Components.Schemas, "TreeNode", "Recursive types must be declared on the components")
+
+	_, err := json.Marshal(doc)
+	assert.Nil(t, err, "The document must be encodable as JSON")
+}
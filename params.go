@@ -0,0 +1,91 @@
+package lotus
+
+import (
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"reflect"
+	"strings"
+)
+
+// tagName is the struct tag used to configure how lotus binds Data fields. The tag holds comma separated key=value
+// pairs. The "path" key binds the field to a route param, e.g. `lotus:"path=id"` binds the field to ":id"
+const tagName = "lotus"
+
+// paramBinding is a Data field bound to a route param
+type paramBinding struct {
+	index int
+	param string
+}
+
+// fieldTag returns the value of key on the lotus tag of field
+func fieldTag(field reflect.StructField, key string) (string, bool) {
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		return "", false
+	}
+	for _, option := range strings.Split(tag, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// pathBindings returns the fields of typ bound to route params
+func pathBindings(typ reflect.Type) []paramBinding {
+	if typ == nil {
+		return nil
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	var bindings []paramBinding
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if param, ok := fieldTag(field, "path"); ok && param != "" && field.PkgPath == "" {
+			bindings = append(bindings, paramBinding{i, param})
+		}
+	}
+	return bindings
+}
+
+// bindRouteParams sets the route params matched by the router on the bound fields of the struct v
+func bindRouteParams(ctx *fasthttp.RequestCtx, v reflect.Value, bindings []paramBinding) error {
+	for _, binding := range bindings {
+		value, ok := ctx.UserValue(binding.param).(string)
+		if !ok {
+			continue
+		}
+		if err := valueToField(v.Field(binding.index), value); err != nil {
+			return fmt.Errorf("invalid route param %s: %w", binding.param, err)
+		}
+	}
+	return nil
+}
+
+// routeParamsFromData returns the route params of the fields of data bound by a path tag
+func routeParamsFromData(data interface{}) map[string]string {
+	params := map[string]string{}
+	if data == nil {
+		return params
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return params
+	}
+	for _, binding := range pathBindings(v.Type()) {
+		field := v.Field(binding.index)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		params[binding.param] = formatValue(field)
+	}
+	return params
+}
@@ -0,0 +1,120 @@
+package lotus
+
+import (
+	"fmt"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"net"
+	"testing"
+)
+
+type UserParams struct {
+	ID   int    `lotus:"path=id"`
+	Slot string `lotus:"path=slot"`
+	Name string
+}
+
+func TestRouteParamsBinding(t *testing.T) {
+	path := "/users/:id/:slot"
+	contract := &RouteContract{
+		Label:  "User",
+		Method: PUT,
+		Path:   path,
+		Data:   UserParams{},
+	}
+	route := &Route{RouteContract: contract, RequestHandler: func(ctx *Context) {
+		params, err := Payload[UserParams](ctx)
+		if err != nil {
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.WriteString(fmt.Sprintf("%d/%s/%s", params.ID, params.Slot, params.Name))
+	}}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10085"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{
+		RouteParams: map[string]string{"slot": "main slot"},
+		Body:        UserParams{ID: 42, Slot: "ignored", Name: "lotus"},
+	})
+	assert.Nil(t, err, "Preparing the request must not return an error")
+	assert.Equal(t, "/users/42/main%20slot", string(req.URI().PathOriginal()), "Route params must be filled from the body tags and RouteParams")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, "42/main slot/lotus", string(resp.Body()), "Route params must be bound into the payload")
+
+	req.SetRequestURI("http://" + url + "/users/abc/main")
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode(), "Invalid route params must return a bad request")
+
+	req.SetRequestURI("http://" + url + path)
+	err = contract.prepareRequest(req, ServiceRequest{})
+	assert.NotNil(t, err, "Preparing a request without route params must return an error")
+}
+
+type UserQuery struct {
+	ID   int `lotus:"path=id"`
+	Name string
+	Tags []string
+}
+
+func TestQueryData(t *testing.T) {
+	path := "/users/:id"
+	contract := &RouteContract{
+		Label: "FindUser",
+		Path:  path,
+		Data:  UserQuery{},
+	}
+	route := &Route{RouteContract: contract, RequestHandler: func(ctx *Context) {
+		query, err := Payload[UserQuery](ctx)
+		if err != nil {
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.WriteString(fmt.Sprintf("%d/%s/%v", query.ID, query.Name, query.Tags))
+	}}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10110"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{
+		QueryParams: map[string]string{"page": "2"},
+		Body:        UserQuery{ID: 42, Name: "lotus", Tags: []string{"a", "b"}},
+	})
+	assert.Nil(t, err, "Preparing the request must not return an error")
+	assert.Empty(t, req.Body(), "Requests without body must not send the data as body")
+	assert.Equal(t, "page=2&Name=lotus&Tags=a&Tags=b", string(req.URI().QueryString()),
+		"The data of requests without body must be sent as query args, as documented by OpenAPI")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, "42/lotus/[a b]", string(resp.Body()), "Query args must be decoded into the payload")
+}
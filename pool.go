@@ -0,0 +1,182 @@
+package lotus
+
+import (
+	"crypto/tls"
+	"github.com/valyala/fasthttp"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientOptions tunes the connections of a ServiceClient to each instance of a service. Zero values use the fasthttp
+// defaults
+type ClientOptions struct {
+	// MaxConns is the maximum number of connections to each instance
+	MaxConns int `json:"maxConns,omitempty" yaml:"maxConns,omitempty"`
+	// MaxConnWaitTimeout is the time a request waits for a free connection once MaxConns is reached. Requests fail
+	// right away when zero
+	MaxConnWaitTimeout time.Duration `json:"maxConnWaitTimeout,omitempty" yaml:"maxConnWaitTimeout,omitempty"`
+	// MaxIdleConnDuration is the time idle keep-alive connections are kept open
+	MaxIdleConnDuration time.Duration `json:"maxIdleConnDuration,omitempty" yaml:"maxIdleConnDuration,omitempty"`
+	// MaxConnDuration is the time a keep-alive connection is kept open
+	MaxConnDuration time.Duration `json:"maxConnDuration,omitempty" yaml:"maxConnDuration,omitempty"`
+	// DialTimeout bounds the connection to an instance
+	DialTimeout time.Duration `json:"dialTimeout,omitempty" yaml:"dialTimeout,omitempty"`
+	// ReadTimeout and WriteTimeout bound reading responses and writing requests
+	ReadTimeout  time.Duration `json:"readTimeout,omitempty" yaml:"readTimeout,omitempty"`
+	WriteTimeout time.Duration `json:"writeTimeout,omitempty" yaml:"writeTimeout,omitempty"`
+	// ReadBufferSize and WriteBufferSize are the per connection buffer sizes. ReadBufferSize also limits the size of
+	// response headers
+	ReadBufferSize  int `json:"readBufferSize,omitempty" yaml:"readBufferSize,omitempty"`
+	WriteBufferSize int `json:"writeBufferSize,omitempty" yaml:"writeBufferSize,omitempty"`
+	// DisableKeepAlive closes the connection after each request
+	DisableKeepAlive bool `json:"disableKeepAlive,omitempty" yaml:"disableKeepAlive,omitempty"`
+}
+
+// PoolStatus reports the connections of a ServiceClient to an instance of a service, as reported by ServiceStatus
+type PoolStatus struct {
+	Service string
+	Address string
+	// OpenConns is the number of connections currently open
+	OpenConns int
+	// PendingRequests is the number of requests in flight
+	PendingRequests int
+	// Requests is the number of requests sent
+	Requests int
+}
+
+// ClientPool keeps a fasthttp.HostClient per instance of a service, configured by its ClientOptions. Retries are left
+// to the ServiceClient RetryPolicy. ServiceClients created by a Service get a pool configured by the ServiceContract
+// ClientOptions of the subscribed service. Clients without pool use the fasthttp default client. Hosts left idle for
+// longer than MaxIdleConnDuration, with no connection open, are dropped as instances come and go. The zero value is
+// ready to use
+type ClientPool struct {
+	ClientOptions
+
+	mu    sync.Mutex
+	hosts map[string]*poolHost
+}
+
+type poolHost struct {
+	client    *fasthttp.HostClient
+	openConns int64
+	requests  int64
+	// lastUsed is the time the host was last returned by host. It's guarded by the pool mu
+	lastUsed time.Time
+}
+
+// idle reports whether the host has no connection open nor request in flight since before
+func (host *poolHost) idle(before time.Time) bool {
+	return atomic.LoadInt64(&host.openConns) == 0 && host.client.PendingRequests() == 0 &&
+		host.lastUsed.Before(before)
+}
+
+// host returns the host of the instance at address, creating it on first use. Returned hosts are marked as used
+// before the lock is released, so they can't be dropped before their request is sent
+func (p *ClientPool) host(address string, isTLS bool, config *tls.Config) *poolHost {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if host, ok := p.hosts[address]; ok {
+		host.lastUsed = time.Now()
+		return host
+	}
+	if p.hosts == nil {
+		p.hosts = map[string]*poolHost{}
+	}
+	p.dropIdleHosts()
+
+	host := &poolHost{lastUsed: time.Now()}
+	dialTimeout := p.DialTimeout
+	host.client = &fasthttp.HostClient{
+		Addr:                      address,
+		IsTLS:                     isTLS,
+		TLSConfig:                 config,
+		MaxConns:                  p.MaxConns,
+		MaxConnWaitTimeout:        p.MaxConnWaitTimeout,
+		MaxIdleConnDuration:       p.MaxIdleConnDuration,
+		MaxConnDuration:           p.MaxConnDuration,
+		ReadTimeout:               p.ReadTimeout,
+		WriteTimeout:              p.WriteTimeout,
+		ReadBufferSize:            p.ReadBufferSize,
+		WriteBufferSize:           p.WriteBufferSize,
+		MaxIdemponentCallAttempts: 1,
+		Dial: func(addr string) (net.Conn, error) {
+			var conn net.Conn
+			var err error
+			if dialTimeout > 0 {
+				conn, err = fasthttp.DialTimeout(addr, dialTimeout)
+			} else {
+				conn, err = fasthttp.Dial(addr)
+			}
+			if err != nil {
+				return nil, err
+			}
+			atomic.AddInt64(&host.openConns, 1)
+			return &countedConn{Conn: conn, open: &host.openConns}, nil
+		},
+	}
+	p.hosts[address] = host
+	return host
+}
+
+// dropIdleHosts forgets the hosts idle for longer than MaxIdleConnDuration, so instances no longer resolved don't
+// pile up. It must be called with mu held
+func (p *ClientPool) dropIdleHosts() {
+	idleDuration := p.MaxIdleConnDuration
+	if idleDuration <= 0 {
+		idleDuration = fasthttp.DefaultMaxIdleConnDuration
+	}
+	before := time.Now().Add(-idleDuration)
+	for address, host := range p.hosts {
+		if host.idle(before) {
+			delete(p.hosts, address)
+		}
+	}
+}
+
+// do sends the request to the instance its URI points to. A zero deadline means no deadline
+func (p *ClientPool) do(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time, config *tls.Config) error {
+	uri := req.URI()
+	host := p.host(string(uri.Host()), string(uri.Scheme()) == HTTPS, config)
+	atomic.AddInt64(&host.requests, 1)
+	if p.DisableKeepAlive {
+		req.SetConnectionClose()
+	}
+	if deadline.IsZero() {
+		return host.client.Do(req, resp)
+	}
+	return host.client.DoDeadline(req, resp, deadline)
+}
+
+// Status returns the connections to each instance, sorted by address. Service is left empty
+func (p *ClientPool) Status() []PoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := make([]PoolStatus, 0, len(p.hosts))
+	for address, host := range p.hosts {
+		status = append(status, PoolStatus{
+			Address:         address,
+			OpenConns:       int(atomic.LoadInt64(&host.openConns)),
+			PendingRequests: host.client.PendingRequests(),
+			Requests:        int(atomic.LoadInt64(&host.requests)),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Address < status[j].Address })
+	return status
+}
+
+// countedConn decrements the open connections of its host once closed
+type countedConn struct {
+	net.Conn
+	open   *int64
+	closed int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.open, -1)
+	}
+	return c.Conn.Close()
+}
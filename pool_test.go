@@ -0,0 +1,67 @@
+package lotus
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientPool(t *testing.T) {
+	instance := startInstance(t, 10104)
+	defer instance.Stop()
+
+	contract := InstancesServiceContract
+	contract.Port = 10104
+	contract.ClientOptions = &ClientOptions{MaxConns: 1, MaxConnWaitTimeout: time.Second, DialTimeout: time.Second}
+	subscriber := Service{ServiceContract: &SecureServiceContract}
+	subscriber.SubscribeToService(contract)
+	subscriber.startServiceClients()
+	client := subscriber.serviceClients[0]
+	assert.Equal(t, 1, client.Pool.MaxConns, "Subscribed clients must be configured by the ServiceContract ClientOptions")
+
+	for i := 0; i < 3; i++ {
+		var address string
+		err := client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, &address)
+		assert.Nil(t, err, "Calling through a pool must not return an error")
+		assert.Equal(t, "127.0.0.1:10104", address)
+	}
+
+	status := subscriber.Status()
+	assert.Equal(t, []PoolStatus{{Service: "Instances", Address: "localhost:10104", OpenConns: 1, Requests: 3}}, status.Pools, "Keep-alive connections must be reused and reported by the service status")
+
+	pool := &ClientPool{ClientOptions: ClientOptions{DisableKeepAlive: true}}
+	client = ServiceClient{ServiceContract: &contract, Pool: pool}
+	for i := 0; i < 2; i++ {
+		resp, err := client.SendRequest(InstanceRouteContract, ServiceRequest{})
+		assert.Nil(t, err)
+		assert.True(t, resp.ConnectionClose(), "Requests must close their connection when keep-alive is disabled")
+		fasthttp.ReleaseResponse(resp)
+	}
+	assert.Eventually(t, func() bool {
+		return pool.Status()[0].OpenConns == 0
+	}, time.Second, 10*time.Millisecond, "Closed connections must not be reported as open")
+	assert.Equal(t, 2, pool.Status()[0].Requests)
+
+	pool = &ClientPool{ClientOptions: ClientOptions{MaxIdleConnDuration: 20 * time.Millisecond}}
+	client = ServiceClient{ServiceContract: &contract, Pool: pool}
+	resp, err := client.SendRequest(InstanceRouteContract, ServiceRequest{})
+	assert.Nil(t, err)
+	fasthttp.ReleaseResponse(resp)
+	assert.Eventually(t, func() bool {
+		return pool.Status()[0].OpenConns == 0
+	}, time.Second, 10*time.Millisecond, "Idle connections must be closed after MaxIdleConnDuration")
+	time.Sleep(20 * time.Millisecond)
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp = fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	url, _ := contract.RouteUrl(InstanceRouteContract.Label)
+	req.SetRequestURI(strings.Replace(url, "localhost", "127.0.0.1", 1))
+	assert.Nil(t, pool.do(req, resp, time.Time{}, nil))
+	pools := pool.Status()
+	assert.Len(t, pools, 1, "Idle hosts must be dropped once another instance is used")
+	assert.Equal(t, "127.0.0.1:10104", pools[0].Address)
+}
@@ -0,0 +1,65 @@
+package lotus
+
+import (
+	"fmt"
+	"github.com/brunvieira/fastalice"
+	"github.com/valyala/fasthttp"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Panic describes a panic recovered while serving a request
+type Panic struct {
+	// Service and Route are the labels of the service and route serving the request
+	Service string
+	Route   string
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic serving %s %s: %v", p.Service, p.Route, p.Value)
+}
+
+// PanicReporter receives the panics recovered by a Service, e.g. to send them to an error tracker. The response is
+// written once the reporter returns
+type PanicReporter func(ctx *fasthttp.RequestCtx, p *Panic)
+
+// LogPanic is the default PanicReporter. It logs the panic and its stack trace
+func LogPanic(_ *fasthttp.RequestCtx, p *Panic) {
+	log.Printf("%s\n%s", p, p.Stack)
+}
+
+// recoverer returns the outermost layer of the chain of the route labeled label. It recovers panics of middlewares,
+// DataHandler and RequestHandler, reports them and answers ErrInternal encoded as dataType
+func (service *Service) recoverer(label string, dataType DataType) fastalice.Constructor {
+	reporter := service.PanicReporter
+	if reporter == nil {
+		reporter = LogPanic
+	}
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				atomic.AddUint64(&service.panics, 1)
+				reporter(ctx, &Panic{Service: service.Label, Route: label, Value: value, Stack: debug.Stack()})
+				writeError(ctx, dataType, ErrInternal)
+			}()
+			next(ctx)
+		}
+	}
+}
+
+// recovered wraps handler, served outside of the routes, with the recoverer unless DisableRecovery is set
+func (service *Service) recovered(label string, dataType DataType, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if service.DisableRecovery {
+		return handler
+	}
+	return service.recoverer(label, dataType)(handler)
+}
@@ -0,0 +1,119 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/brunvieira/fastalice"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"sync"
+	"testing"
+)
+
+var (
+	PanicRouteContract = RouteContract{
+		Label: "Panic",
+		Path:  "/panic",
+	}
+	PanicMiddlewareRouteContract = RouteContract{
+		Label: "PanicMiddleware",
+		Path:  "/panic/middleware",
+	}
+	RecoveryServiceContract = ServiceContract{
+		Label:           "Recovery",
+		Port:            10106,
+		RoutesContracts: []RouteContract{PanicRouteContract, PanicMiddlewareRouteContract},
+	}
+)
+
+func TestRecovery(t *testing.T) {
+	var mu sync.Mutex
+	var reported []*Panic
+	service := Service{
+		ServiceContract: &RecoveryServiceContract,
+		PanicReporter: func(ctx *fasthttp.RequestCtx, p *Panic) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, p)
+		},
+	}
+	service.SetupRoute("Panic", func(ctx *Context) {
+		ctx.WriteString("partial")
+		panic("handler failed")
+	}, nil, nil)
+	service.SetupRoute("PanicMiddleware", echo, []fastalice.Constructor{
+		func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+			return func(ctx *fasthttp.RequestCtx) {
+				panic(errors.New("middleware failed"))
+			}
+		},
+	}, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &RecoveryServiceContract}
+	for i := 0; i < 2; i++ {
+		err := client.Call(context.Background(), PanicRouteContract, ServiceRequest{}, nil)
+		assert.True(t, errors.Is(err, ErrInternal), "Panics must be answered with ErrInternal")
+		var respErr *ResponseError
+		assert.True(t, errors.As(err, &respErr))
+		assert.Equal(t, fasthttp.StatusInternalServerError, respErr.StatusCode)
+		assert.NotContains(t, string(respErr.Body), "partial", "The partial response must be discarded")
+		assert.NotContains(t, string(respErr.Body), "handler failed", "Panic values must not be answered")
+	}
+
+	err := client.Call(context.Background(), PanicMiddlewareRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrInternal), "Panics of middlewares must be recovered")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, reported, 3, "Every panic must be reported")
+	assert.Equal(t, "Recovery", reported[0].Service)
+	assert.Equal(t, "Panic", reported[0].Route)
+	assert.Equal(t, "handler failed", reported[0].Value)
+	assert.Contains(t, string(reported[0].Stack), "recovery_test.go", "The stack trace of the panic must be reported")
+	assert.Equal(t, "PanicMiddleware", reported[2].Route)
+	assert.Equal(t, uint64(3), service.Status().Panics, "Panics must be counted by the service status")
+}
+
+func TestDisableRecovery(t *testing.T) {
+	service := Service{ServiceContract: &RecoveryServiceContract, DisableRecovery: true}
+	service.SetupRoute("Panic", echo, nil, nil)
+	service.createRouter()
+	service.startRoutes()
+	assert.Nil(t, service.routes[0].recovery, "Routes must not recover panics when recovery is disabled")
+}
+
+func TestRecoverFallbacks(t *testing.T) {
+	var reported []*Panic
+	service := Service{
+		ServiceContract: &RecoveryServiceContract,
+		PanicReporter: func(ctx *fasthttp.RequestCtx, p *Panic) {
+			reported = append(reported, p)
+		},
+	}
+	service.SetupRoute("Panic", echo, nil, nil)
+	service.createRouter()
+	service.router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		panic("not found failed")
+	}
+	service.router.MethodNotAllowed = func(ctx *fasthttp.RequestCtx) {
+		panic("method not allowed failed")
+	}
+	service.startRoutes()
+
+	for _, method := range []string{"GET", "POST"} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		path := service.Suffix() + "/missing"
+		if method == "POST" {
+			path = service.Suffix() + PanicRouteContract.Path
+		}
+		ctx.Request.SetRequestURI(path)
+		assert.NotPanics(t, func() { service.router.Handler(ctx) }, "Panics of the router fallbacks must be recovered")
+		assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	}
+	assert.Len(t, reported, 2)
+	assert.Equal(t, "NotFound", reported[0].Route)
+	assert.Equal(t, "MethodNotAllowed", reported[1].Route)
+}
@@ -0,0 +1,197 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDNSResolverTTL is the time DNSResolver caches the instances of a service
+	DefaultDNSResolverTTL = 30 * time.Second
+	// DefaultFileResolverInterval is the interval FileResolver checks its file for changes
+	DefaultFileResolverInterval = time.Second
+)
+
+// ErrNoInstances is returned when a Resolver doesn't find any instance of a service
+var ErrNoInstances = errors.New("no instances found")
+
+// Resolver finds the addresses of the instances of a service. Addresses are in the "host:port" form
+type Resolver interface {
+	Resolve(ctx context.Context, service *ServiceContract) ([]string, error)
+}
+
+// StaticResolver resolves every service to the same fixed addresses
+type StaticResolver []string
+
+func (r StaticResolver) Resolve(_ context.Context, service *ServiceContract) ([]string, error) {
+	if len(r) == 0 {
+		return nil, fmt.Errorf("%s: %w", service.Label, ErrNoInstances)
+	}
+	return append([]string(nil), r...), nil
+}
+
+// MemoryResolver keeps the addresses of each service in memory. It's meant for tests. The zero value is ready to use
+type MemoryResolver struct {
+	mu        sync.RWMutex
+	instances map[string][]string
+}
+
+// Set replaces the addresses of the service with the given label
+func (r *MemoryResolver) Set(label string, addresses ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.instances == nil {
+		r.instances = map[string][]string{}
+	}
+	r.instances[label] = append([]string(nil), addresses...)
+}
+
+func (r *MemoryResolver) Resolve(_ context.Context, service *ServiceContract) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	addresses := r.instances[service.Label]
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s: %w", service.Label, ErrNoInstances)
+	}
+	return append([]string(nil), addresses...), nil
+}
+
+// DNSResolver resolves services with DNS SRV records. Services are looked up as _Service._Proto.Name, or by Name
+// directly when Service and Proto are empty. Name defaults to the service Host
+type DNSResolver struct {
+	Service string
+	Proto   string
+	Name    string
+	// TTL is the time the instances of a service are cached. Defaults to DefaultDNSResolverTTL
+	TTL time.Duration
+	// LookupSRV performs the lookup. Defaults to net.DefaultResolver.LookupSRV
+	LookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addresses []string
+	expires   time.Time
+}
+
+func (r *DNSResolver) Resolve(ctx context.Context, service *ServiceContract) ([]string, error) {
+	name := r.Name
+	if name == "" {
+		name = service.host()
+	}
+	key := r.Service + "." + r.Proto + "." + name
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return append([]string(nil), entry.addresses...), nil
+	}
+
+	lookup := r.LookupSRV
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+	_, records, err := lookup(ctx, r.Service, r.Proto, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: %w", service.Label, ErrNoInstances)
+	}
+	// records are ordered by priority and randomized by weight by the lookup
+	addresses := make([]string, len(records))
+	for i, record := range records {
+		addresses[i] = net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+	}
+
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultDNSResolverTTL
+	}
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = map[string]dnsEntry{}
+	}
+	r.cache[key] = dnsEntry{addresses: addresses, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return append([]string(nil), addresses...), nil
+}
+
+// FileResolver resolves services from a YAML or JSON file mapping service labels to their addresses, e.g.
+//
+//	Users: ["10.0.0.1:8080", "10.0.0.2:8080"]
+//
+// The file is reloaded when it changes, so deploy tooling can update the instances of running services
+type FileResolver struct {
+	Path string
+	// Interval is the minimum time between checks for changes on the file. Defaults to DefaultFileResolverInterval
+	Interval time.Duration
+
+	mu        sync.Mutex
+	checked   time.Time
+	modTime   time.Time
+	instances map[string][]string
+}
+
+func (r *FileResolver) Resolve(_ context.Context, service *ServiceContract) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	addresses := r.instances[service.Label]
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s: %w", service.Label, ErrNoInstances)
+	}
+	return append([]string(nil), addresses...), nil
+}
+
+// reload reads the file if it changed since the last check. The last instances are kept while the file can't be
+// read or parsed
+func (r *FileResolver) reload() error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultFileResolverInterval
+	}
+	if r.instances != nil && time.Since(r.checked) < interval {
+		return nil
+	}
+	r.checked = time.Now()
+
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		if r.instances != nil {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", r.Path, err)
+	}
+	if r.instances != nil && info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	instances := map[string][]string{}
+	data, err := os.ReadFile(r.Path)
+	if err == nil {
+		err = yaml.Unmarshal(data, &instances)
+	}
+	if err != nil {
+		if r.instances != nil {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", r.Path, err)
+	}
+	r.instances = instances
+	r.modTime = info.ModTime()
+	return nil
+}
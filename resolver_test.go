@@ -0,0 +1,128 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	InstanceRouteContract = RouteContract{
+		Label: "Instance",
+		Path:  "/instance",
+	}
+	InstancesServiceContract = ServiceContract{
+		Label:           "Instances",
+		Port:            10095,
+		RoutesContracts: []RouteContract{InstanceRouteContract},
+	}
+)
+
+// startInstance starts an instance of the InstancesServiceContract answering with its address
+func startInstance(t *testing.T, port int) *Service {
+	contract := InstancesServiceContract
+	contract.Port = port
+	service := &Service{ServiceContract: &contract}
+	service.SetupRoute("Instance", func(ctx *Context) {
+		ctx.WriteString(ctx.LocalAddr().String())
+	}, nil, nil)
+	startService(t, service)
+	return service
+}
+
+func TestResolver(t *testing.T) {
+	first := startInstance(t, 10095)
+	defer first.Stop()
+	second := startInstance(t, 10096)
+	defer second.Stop()
+
+	resolver := &MemoryResolver{}
+	client := ServiceClient{ServiceContract: &InstancesServiceContract, Resolver: resolver}
+	err := client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, ErrNoInstances), "Calling a service without instances must return ErrNoInstances")
+
+	resolver.Set("Instances", "127.0.0.1:10095", "127.0.0.1:10096")
+	called := map[string]int{}
+	for i := 0; i < 50; i++ {
+		var address string
+		err = client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, &address)
+		assert.Nil(t, err, "Calling a resolved instance must not return an error")
+		called[address]++
+	}
+	assert.Len(t, called, 2, "Requests must be sent to every resolved instance")
+
+	client.Resolver = StaticResolver{"127.0.0.1:10096"}
+	var address string
+	err = client.Call(context.Background(), InstanceRouteContract, ServiceRequest{}, &address)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:10096", address, "Static addresses must be used")
+
+	subscriber := Service{ServiceContract: &SecureServiceContract, Resolver: resolver}
+	subscriber.SubscribeToService(InstancesServiceContract)
+	subscriber.startServiceClients()
+	assert.Equal(t, resolver, subscriber.serviceClients[0].Resolver, "Subscribed clients must use the service Resolver")
+}
+
+func TestFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	resolver := &FileResolver{Path: path, Interval: time.Nanosecond}
+
+	_, err := resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.NotNil(t, err, "Resolving from a missing file must return an error")
+
+	os.WriteFile(path, []byte(`Instances: ["10.0.0.1:80", "10.0.0.2:80"]`), 0644)
+	addresses, err := resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.Nil(t, err, "Resolving from a file must not return an error")
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.2:80"}, addresses)
+
+	os.WriteFile(path, []byte(`{"Instances": ["10.0.0.3:80"]}`), 0644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	addresses, _ = resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.Equal(t, []string{"10.0.0.3:80"}, addresses, "Changes on the file must be reloaded")
+
+	os.WriteFile(path, []byte(`Instances: [`), 0644)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute))
+	addresses, err = resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.Nil(t, err, "An invalid file must keep the last instances")
+	assert.Equal(t, []string{"10.0.0.3:80"}, addresses)
+
+	_, err = resolver.Resolve(context.Background(), &SecureServiceContract)
+	assert.True(t, errors.Is(err, ErrNoInstances), "Services missing from the file must return ErrNoInstances")
+}
+
+func TestDNSResolver(t *testing.T) {
+	lookups := 0
+	resolver := &DNSResolver{
+		Service: "http",
+		Proto:   "tcp",
+		LookupSRV: func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			lookups++
+			assert.Equal(t, "http", service)
+			assert.Equal(t, "tcp", proto)
+			assert.Equal(t, "localhost", name, "The service Host must be looked up by default")
+			return "_http._tcp.localhost.", []*net.SRV{
+				{Target: "first.localhost.", Port: 8080},
+				{Target: "second.localhost.", Port: 8081},
+			}, nil
+		},
+	}
+
+	addresses, err := resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.Nil(t, err, "Resolving SRV records must not return an error")
+	assert.Equal(t, []string{"first.localhost:8080", "second.localhost:8081"}, addresses)
+
+	resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.Equal(t, 1, lookups, "Instances must be cached")
+
+	resolver.LookupSRV = func(context.Context, string, string, string) (string, []*net.SRV, error) {
+		return "", nil, nil
+	}
+	resolver.Name = "other.localhost"
+	_, err = resolver.Resolve(context.Background(), &InstancesServiceContract)
+	assert.True(t, errors.Is(err, ErrNoInstances), "Resolving no records must return ErrNoInstances")
+}
@@ -0,0 +1,118 @@
+package lotus
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"math/rand"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader carries the ServiceRequest IdempotencyKey so services can deduplicate retried requests
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// DefaultRetryInitialBackoff is the wait before the first retry
+	DefaultRetryInitialBackoff = 50 * time.Millisecond
+	// DefaultRetryMaxBackoff bounds the wait between retries
+	DefaultRetryMaxBackoff = 2 * time.Second
+	// DefaultRetryMultiplier is the growth of the wait after each retry
+	DefaultRetryMultiplier = 2
+)
+
+// DefaultRetryableStatuses are the status codes retried when a RetryPolicy doesn't set RetryableStatuses
+var DefaultRetryableStatuses = []int{
+	fasthttp.StatusTooManyRequests,
+	fasthttp.StatusBadGateway,
+	fasthttp.StatusServiceUnavailable,
+	fasthttp.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures how a ServiceClient retries failed requests. A request is retried when it fails with a
+// network error or a retryable status. Only idempotent methods are retried, unless the request carries an
+// IdempotencyKey or RetryNonIdempotent is set. Waits between attempts grow exponentially with jitter and stop early
+// when the request context is done. Each attempt picks an instance again
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. Requests aren't retried below 2
+	MaxAttempts int `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	// InitialBackoff is the wait before the first retry. Defaults to DefaultRetryInitialBackoff
+	InitialBackoff time.Duration `json:"initialBackoff,omitempty" yaml:"initialBackoff,omitempty"`
+	// MaxBackoff bounds the wait between retries. Defaults to DefaultRetryMaxBackoff
+	MaxBackoff time.Duration `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+	// Multiplier is the growth of the wait after each retry. Defaults to DefaultRetryMultiplier
+	Multiplier float64 `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	// RetryableStatuses are the response status codes retried. Defaults to DefaultRetryableStatuses
+	RetryableStatuses []int `json:"retryableStatuses,omitempty" yaml:"retryableStatuses,omitempty"`
+	// IgnoreNetworkErrors disables retries of requests that failed to get a response
+	IgnoreNetworkErrors bool `json:"ignoreNetworkErrors,omitempty" yaml:"ignoreNetworkErrors,omitempty"`
+	// RetryNonIdempotent retries every method, with or without IdempotencyKey
+	RetryNonIdempotent bool `json:"retryNonIdempotent,omitempty" yaml:"retryNonIdempotent,omitempty"`
+}
+
+// idempotent reports whether requests with the method can be sent more than once with the same effect
+func idempotent(method string) bool {
+	switch method {
+	case GET, HEAD, PUT, string(DELETE), OPTIONS, TRACE:
+		return true
+	}
+	return false
+}
+
+// allows reports whether a request with the method and idempotency key can be retried
+func (p *RetryPolicy) allows(method, idempotencyKey string) bool {
+	if p == nil || p.MaxAttempts < 2 {
+		return false
+	}
+	return p.RetryNonIdempotent || idempotencyKey != "" || idempotent(method)
+}
+
+// retryable reports whether the outcome of an attempt must be retried
+func (p *RetryPolicy) retryable(err error, status int) bool {
+	if err != nil {
+		return !p.IgnoreNetworkErrors
+	}
+	statuses := p.RetryableStatuses
+	if statuses == nil {
+		statuses = DefaultRetryableStatuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the given attempt, randomized between half and the whole exponential backoff
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initial, max, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	wait := float64(initial)
+	for i := 1; i < attempt && wait < float64(max); i++ {
+		wait *= multiplier
+	}
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+	half := wait / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+// wait sleeps the backoff of the attempt. It returns the ctx error if ctx is done first
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,128 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	FlakyGetRouteContract = RouteContract{
+		Label:  "FlakyGet",
+		Method: GET,
+		Path:   "/flaky",
+	}
+	FlakyPostRouteContract = RouteContract{
+		Label:  "FlakyPost",
+		Method: POST,
+		Path:   "/flaky",
+	}
+	FlakyServiceContract = ServiceContract{
+		Label:           "Flaky",
+		Port:            10100,
+		RoutesContracts: []RouteContract{FlakyGetRouteContract, FlakyPostRouteContract},
+	}
+)
+
+// flakyHandler answers 503 to the first failures requests and records the idempotency keys received
+type flakyHandler struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	keys     []string
+}
+
+func (h *flakyHandler) reset(failures int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures, h.attempts, h.keys = failures, 0, nil
+}
+
+func (h *flakyHandler) handle(ctx *Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts++
+	h.keys = append(h.keys, string(ctx.Request.Header.Peek(IdempotencyKeyHeader)))
+	if h.attempts <= h.failures {
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		return
+	}
+	ctx.WriteString("ok")
+}
+
+func TestRetryPolicy(t *testing.T) {
+	handler := &flakyHandler{}
+	service := Service{ServiceContract: &FlakyServiceContract}
+	service.SetupRoute("FlakyGet", handler.handle, nil, nil)
+	service.SetupRoute("FlakyPost", handler.handle, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	client := ServiceClient{ServiceContract: &FlakyServiceContract, Retry: policy}
+
+	handler.reset(2)
+	err := client.Call(context.Background(), FlakyGetRouteContract, ServiceRequest{}, nil)
+	assert.Nil(t, err, "Idempotent requests must be retried")
+	assert.Equal(t, 3, handler.attempts)
+
+	handler.reset(3)
+	err = client.Call(context.Background(), FlakyGetRouteContract, ServiceRequest{}, nil)
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr), "The last response must be returned once attempts are exhausted")
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, respErr.StatusCode)
+	assert.Equal(t, 3, handler.attempts, "Requests must not be attempted more than MaxAttempts")
+
+	handler.reset(1)
+	err = client.Call(context.Background(), FlakyPostRouteContract, ServiceRequest{}, nil)
+	assert.NotNil(t, err, "POST requests without idempotency key must not be retried")
+	assert.Equal(t, 1, handler.attempts)
+
+	handler.reset(1)
+	err = client.Call(context.Background(), FlakyPostRouteContract, ServiceRequest{IdempotencyKey: "order-1"}, nil)
+	assert.Nil(t, err, "POST requests with an idempotency key must be retried")
+	assert.Equal(t, []string{"order-1", "order-1"}, handler.keys, "The idempotency key must be sent on every attempt")
+
+	handler.reset(1)
+	route := FlakyGetRouteContract
+	route.Retry = &RetryPolicy{MaxAttempts: 1}
+	err = client.Call(context.Background(), route, ServiceRequest{}, nil)
+	assert.NotNil(t, err, "The route RetryPolicy must override the client one")
+	assert.Equal(t, 1, handler.attempts)
+
+	handler.reset(0)
+	client.Resolver = StaticResolver{"127.0.0.1:10101", "127.0.0.1:10100"}
+	client.Balancer = &RoundRobinBalancer{}
+	err = client.Call(context.Background(), FlakyGetRouteContract, ServiceRequest{}, nil)
+	assert.Nil(t, err, "Network errors must be retried on another instance")
+
+	handler.reset(3)
+	client.Resolver = nil
+	client.Retry = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = client.Call(ctx, FlakyGetRouteContract, ServiceRequest{}, nil)
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "Waiting for a retry must stop when the context is done")
+	assert.Equal(t, 1, handler.attempts)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		backoff := policy.backoff(attempt + 1)
+		assert.GreaterOrEqual(t, int64(backoff), int64(max/2), "Backoff must not be below half of the exponential wait")
+		assert.LessOrEqual(t, int64(backoff), int64(max), "Backoff must grow exponentially up to MaxBackoff")
+	}
+
+	assert.True(t, idempotent(GET))
+	assert.True(t, idempotent(PUT))
+	assert.True(t, idempotent(string(DELETE)))
+	assert.False(t, idempotent(POST))
+	assert.False(t, idempotent(PATCH))
+}
@@ -1,14 +1,17 @@
 package lotus
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"github.com/brunvieira/fastalice"
 	"github.com/buaazp/fasthttprouter"
-	"github.com/mitchellh/mapstructure"
 	"github.com/valyala/fasthttp"
-	"github.com/vmihailenco/msgpack"
+	"mime/multipart"
+	"net/url"
+	"reflect"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Method defines usable methods for endpoints
@@ -23,6 +26,18 @@ const (
 	POST = fasthttp.MethodPost
 	// PUT defines the PUT type of request
 	PUT = fasthttp.MethodPut
+	// PATCH defines the PATCH type of request
+	PATCH = fasthttp.MethodPatch
+	// HEAD defines the HEAD type of request
+	HEAD = fasthttp.MethodHead
+	// OPTIONS defines the OPTIONS type of request
+	OPTIONS = fasthttp.MethodOptions
+	// CONNECT defines the CONNECT type of request
+	CONNECT = fasthttp.MethodConnect
+	// TRACE defines the TRACE type of request
+	TRACE = fasthttp.MethodTrace
+	// ANY defines a route that answers requests of every method not declared by another route on the same path
+	ANY = "ANY"
 
 	// Default values
 	DefaultRouteMethod Method = GET
@@ -35,31 +50,53 @@ const (
 
 var routerParamReg = regexp.MustCompile(`:[a-zA-Z0-9]*`)
 
+// standardMethods are the methods a route can be registered upon. ANY routes are registered on all of them
+var standardMethods = []Method{GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE}
+
 type DataHandlerConfig struct {
-	BodyType     DataType
-	UserValueKey string
+	BodyType     DataType `json:"bodyType,omitempty" yaml:"bodyType,omitempty"`
+	UserValueKey string   `json:"userValueKey,omitempty" yaml:"userValueKey,omitempty"`
+	// MaxBodySize is the maximum size in bytes of the request body accepted by the route. Services check it against
+	// the request headers before reading the body, so it bounds the memory used by requests and may be over the
+	// fasthttp default limit. Zero means no limit other than the server one
+	MaxBodySize int `json:"maxBodySize,omitempty" yaml:"maxBodySize,omitempty"`
+	// MaxMultipartMemory is the maximum amount of bytes of multipart file parts kept in memory. The remaining
+	// parts are stored on temporary files. Defaults to DefaultMaxMultipartMemory
+	MaxMultipartMemory int64 `json:"maxMultipartMemory,omitempty" yaml:"maxMultipartMemory,omitempty"`
 }
 
 // RouteContract is the Contract description of a Route
 type RouteContract struct {
 	// Label is an identification for the route. It's used on status and log information
-	Label string
+	Label string `json:"label" yaml:"label"`
 	// Description is a short text that helps to document the route
-	Description string
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 	// Method is the fasthttp method the route will listen upon
-	Method Method
+	Method Method `json:"method,omitempty" yaml:"method,omitempty"`
 	// Path is the location where the route will listen to
-	Path string
+	Path string `json:"path" yaml:"path"`
 	// DataHandlerConfig is the configuration for the route DataHandler. This is an optional field
-	DataHandlerConfig DataHandlerConfig
+	DataHandlerConfig DataHandlerConfig `json:"dataHandler,omitempty" yaml:"dataHandler,omitempty"`
 	// Data is the Data used. Use an empty struct value
-	Data interface{}
+	Data interface{} `json:"-" yaml:"-"`
+	// Schema documents the Data of contracts loaded from files. It's reflected from Data when Data is set
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	// Definitions holds the schemas of recursive types referenced by Schema
+	Definitions map[string]*Schema `json:"definitions,omitempty" yaml:"definitions,omitempty"`
+	// Retry is the RetryPolicy used by clients calling the route. It overrides the ServiceClient one
+	Retry *RetryPolicy `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Timeout bounds calls to the route and the Context handling them
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 }
 
 func (route *RouteContract) prepareRequest(req *fasthttp.Request, payload ServiceRequest) (err error) {
+	err = Validate(payload.Body)
+	if err != nil {
+		return
+	}
 
-	method := string(route.Method)
-	req.Header.SetMethod(method)
+	method := route.requestMethod(payload)
+	req.Header.SetMethod(string(method))
 
 	err = route.prepareRouteParams(req, payload)
 	if err != nil {
@@ -71,32 +108,30 @@ func (route *RouteContract) prepareRequest(req *fasthttp.Request, payload Servic
 		return
 	}
 
+	if !hasBody(method) {
+		return prepareQueryData(req, payload.Body)
+	}
+
 	dataType := payload.DataType
 	if len(dataType) == 0 {
 		dataType = route.DataType()
 	}
 
-	switch dataType {
-	case JSON:
-		b, err := json.Marshal(payload.Body)
-		req.Header.Set("Content-Type", string(JSON))
-		req.SetBody(b)
-		return err
-	case MultipartForm:
-	case Form:
-		m, err := dataToUrlValues(payload.Body)
+	if dataType == MultipartForm {
+		b, contentType, err := encodeMultipart(payload.Body)
 		if err != nil {
 			return err
 		}
-		req.Header.Set("Content-Type", string(Form))
-		req.SetBodyString(m.Encode())
-		return err
-	default:
-		b, err := msgpack.Marshal(payload.Body)
-		req.Header.Set("Content-Type", string(Binary))
+		req.Header.Set("Content-Type", contentType)
 		req.SetBody(b)
+		return nil
+	}
+	b, contentType, err := encodeBody(dataType, payload.Body)
+	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", string(contentType))
+	req.SetBody(b)
 	return nil
 }
 
@@ -116,15 +151,51 @@ func (route *RouteContract) prepareQueryParams(req *fasthttp.Request, payload Se
 	return nil
 }
 
+// prepareQueryData encodes the data of requests without body as query args. Fields bound to route params are
+// skipped
+func prepareQueryData(req *fasthttp.Request, data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	values, err := dataToUrlValues(data)
+	if err != nil {
+		return err
+	}
+	if v := reflect.Indirect(reflect.ValueOf(data)); v.Kind() == reflect.Struct {
+		for _, binding := range pathBindings(v.Type()) {
+			delete(values, v.Type().Field(binding.index).Name)
+		}
+	}
+	query := values.Encode()
+	if query == "" {
+		return nil
+	}
+	if existing := req.URI().QueryString(); len(existing) > 0 {
+		query = string(existing) + "&" + query
+	}
+	req.URI().SetQueryString(query)
+	return nil
+}
+
 func (route *RouteContract) prepareRouteParams(req *fasthttp.Request, payload ServiceRequest) error {
-	if len(payload.RouteParams) == 0 {
+	names := route.routeParams()
+	if len(names) == 0 {
 		return nil
 	}
 
-	m, err := dataToUrlValues(payload.RouteParams)
+	params := routeParamsFromData(payload.Body)
+	for k, v := range payload.RouteParams {
+		params[k] = v
+	}
+	m, err := dataToUrlValues(params)
 	if err != nil {
 		return err
 	}
+	for _, name := range names {
+		if len(m[name]) == 0 {
+			return fmt.Errorf("missing route param %s for route %s", name, route.Label)
+		}
+	}
 	path := routerParamReg.ReplaceAllFunc([]byte(route.Path), replaceRouteMatches(m))
 	uriStr := req.URI().String()
 	newUri := strings.Replace(uriStr, route.Path, string(path), 1)
@@ -132,6 +203,16 @@ func (route *RouteContract) prepareRouteParams(req *fasthttp.Request, payload Se
 	return nil
 }
 
+// routeParams returns the names of the route params declared on the Path
+func (route *RouteContract) routeParams() []string {
+	matches := routerParamReg.FindAllString(route.Path, -1)
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, match[1:])
+	}
+	return names
+}
+
 func (route *RouteContract) method() Method {
 	if len(route.Method) > 0 {
 		return route.Method
@@ -139,6 +220,31 @@ func (route *RouteContract) method() Method {
 	return DefaultRouteMethod
 }
 
+// requestMethod is the method used by clients. ANY routes are called with POST when there's a body and GET otherwise
+func (route *RouteContract) requestMethod(payload ServiceRequest) Method {
+	if route.method() != ANY {
+		return route.method()
+	}
+	if payload.Body != nil {
+		return POST
+	}
+	return GET
+}
+
+// validateMethod returns an error if the route method is not a standard method or ANY
+func (route *RouteContract) validateMethod() error {
+	method := route.method()
+	if method == ANY {
+		return nil
+	}
+	for _, m := range standardMethods {
+		if m == method {
+			return nil
+		}
+	}
+	return fmt.Errorf("route %s has an unsupported method %q", route.Label, method)
+}
+
 func (route *RouteContract) DataType() DataType {
 	if route.DataHandlerConfig.BodyType != "" {
 		return route.DataHandlerConfig.BodyType
@@ -146,6 +252,39 @@ func (route *RouteContract) DataType() DataType {
 	return DefaultBodyDataType
 }
 
+// schema returns the schema of the route Data and the definitions it references, falling back to Schema when the
+// route has no Data
+func (route *RouteContract) schema() (*Schema, map[string]*Schema) {
+	if route.Data != nil {
+		return SchemaOf(route.Data, route.payloadDataType())
+	}
+	return route.Schema, route.Definitions
+}
+
+// payloadDataType returns the DataType clients encode the route Data with. Data of methods without body is sent as
+// query args
+func (route *RouteContract) payloadDataType() DataType {
+	if !hasBody(route.method()) {
+		return Form
+	}
+	return route.DataType()
+}
+
+// dataType returns the reflect.Type of Data or nil when the route has no Data
+func (route *RouteContract) dataType() reflect.Type {
+	if route.Data == nil {
+		return nil
+	}
+	return reflect.TypeOf(route.Data)
+}
+
+func (route *RouteContract) maxMultipartMemory() int64 {
+	if route.DataHandlerConfig.MaxMultipartMemory > 0 {
+		return route.DataHandlerConfig.MaxMultipartMemory
+	}
+	return DefaultMaxMultipartMemory
+}
+
 func (route *RouteContract) userValueKey() string {
 	if len(route.DataHandlerConfig.UserValueKey) > 0 {
 		return route.DataHandlerConfig.UserValueKey
@@ -160,35 +299,96 @@ type Route struct {
 	RequestHandler RequestHandler
 	// Middlewares functions executed before the RequestHandler
 	Middlewares []fastalice.Constructor
+	// ContextMiddlewares are executed after the DataHandler, with the lotus Context, before the RequestHandler
+	ContextMiddlewares []Middleware
 	// DataHandlers used to receive requests
 	DataHandler fastalice.Constructor
 	// serviceClients holds references to service clients
 	serviceClients []ServiceClient
+	// handler is the request handler built by startRoute
+	handler fasthttp.RequestHandler
+	// recovery is the outermost layer of the chain, set by the Service unless DisableRecovery is set
+	recovery fastalice.Constructor
+	// serviceMiddlewares are the service and group middlewares, executed before Middlewares
+	serviceMiddlewares []fastalice.Constructor
+	// serviceContextMiddlewares are the service and group context middlewares, executed before ContextMiddlewares
+	serviceContextMiddlewares []Middleware
+	// requestHandler is the RequestHandler wrapped by the context middlewares
+	requestHandler RequestHandler
+	// errorMappings are the errors mapped by the Service, answered by Context.Error
+	errorMappings []errorMapping
 }
 
+// startRoute registers the route on the router. ANY routes are registered for every method not yet registered on
+// the same path, so they must be started after the routes with an explicit method
 func (route *Route) startRoute(router *fasthttprouter.Router, prefix string) {
-	handler := startMiddlewares(route)
+	route.handler = startMiddlewares(route)
 	path := prefix + route.Path
-	switch route.method() {
-	case DELETE:
-		router.DELETE(path, handler)
-	case GET:
-		router.GET(path, handler)
-	case POST:
-		router.POST(path, handler)
-	case PUT:
-		router.PUT(path, handler)
+	if route.method() != ANY {
+		router.Handle(string(route.method()), path, route.handler)
+		return
+	}
+	for _, method := range standardMethods {
+		if !isRegistered(router, method, path) {
+			router.Handle(string(method), path, route.handler)
+		}
 	}
 }
 
+// startImplicitRoutes registers the GET route handler for HEAD requests when no route declares HEAD for the same
+// path. fasthttp omits the body of HEAD responses. OPTIONS requests are answered by the router
+func (route *Route) startImplicitRoutes(router *fasthttprouter.Router, prefix string) {
+	path := prefix + route.Path
+	if route.method() == GET && route.handler != nil && !isRegistered(router, HEAD, path) {
+		router.Handle(HEAD, path, route.handler)
+	}
+}
+
+// matchesPattern reports whether path matches a route pattern, whose :params match a segment and *params the rest
+func matchesPattern(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			return true
+		case i >= len(pathSegments):
+			return false
+		case strings.HasPrefix(segment, ":"):
+			if pathSegments[i] == "" {
+				return false
+			}
+		case segment != pathSegments[i]:
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+func isRegistered(router *fasthttprouter.Router, method Method, path string) bool {
+	handler, _ := router.Lookup(string(method), path, nil)
+	return handler != nil
+}
+
 func startMiddlewares(route *Route) fasthttp.RequestHandler {
-	chain := fastalice.New(route.Middlewares...)
+	chain := fastalice.New()
+	if route.recovery != nil {
+		chain = chain.Append(route.recovery)
+	}
+	chain = chain.Append(route.serviceMiddlewares...)
+	chain = chain.Append(route.Middlewares...)
 
 	dh := route.defaultDataHandler
 	if route.DataHandler != nil {
 		dh = route.DataHandler
 	}
 	chain = chain.Append(dh)
+
+	route.requestHandler = route.RequestHandler
+	middlewares := append(append([]Middleware(nil), route.serviceContextMiddlewares...), route.ContextMiddlewares...)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		route.requestHandler = middlewares[i](route.requestHandler)
+	}
 	return chain.Then(route.defaultRequestHandler)
 }
 
@@ -200,36 +400,72 @@ func (route *Route) addServiceClient(client ServiceClient) {
 }
 
 func (route *Route) defaultRequestHandler(ctx *fasthttp.RequestCtx) {
-	lotusCtx := Context{ctx, route.serviceClients}
-	route.RequestHandler(&lotusCtx)
+	lotusCtx := Context{RequestCtx: ctx, ServiceClients: route.serviceClients, route: route}
+	if deadline, ok := requestDeadline(&ctx.Request, ctx.Time(), route.Timeout); ok {
+		if !time.Now().Before(deadline) {
+			writeError(ctx, route.DataType(), ErrDeadlineExceeded)
+			return
+		}
+		var cancel context.CancelFunc
+		lotusCtx.ctx, cancel = context.WithDeadline(serverContext{RequestCtx: ctx, done: ctx.Done()}, deadline)
+		defer cancel()
+	}
+	route.requestHandler(&lotusCtx)
 }
 
 func (route *Route) defaultDataHandler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	typ := route.dataType()
+	key := route.userValueKey()
+	maxBodySize := route.DataHandlerConfig.MaxBodySize
+	maxMemory := route.maxMultipartMemory()
+	bindings := pathBindings(typ)
+	validate := hasValidation(typ)
 	return func(ctx *fasthttp.RequestCtx) {
-		var m map[string]interface{}
 		var err error
 
 		body := ctx.PostBody()
-		key := route.userValueKey()
+		query := ctx.QueryArgs()
+		dataType := mediaType(ctx.Request.Header.ContentType())
 
-		dataType := DataType(ctx.Request.Header.Peek("Content-Type"))
+		// Services reject bodies over the limit before reading them. This covers routes served by another server
+		if maxBodySize > 0 && len(body) > maxBodySize {
+			writeError(ctx, route.DataType(), ErrPayloadTooLarge)
+			return
+		}
 
-		if len(body) > 0 {
-			if dataType == JSON {
-				err = json.Unmarshal(body, &m)
+		if len(body) > 0 || query.Len() > 0 || len(bindings) > 0 || validate {
+			ptr := newData(typ)
+			out := ptr.Interface()
+			if query.Len() > 0 {
+				err = urlValuesToData(argsToUrlValues(query), out)
+			}
+			if err == nil && len(body) > 0 {
+				if dataType == MultipartForm {
+					var form *multipart.Form
+					form, err = decodeMultipart(body, ctx.Request.Header.MultipartFormBoundary(), maxMemory, out)
+					if form != nil {
+						defer form.RemoveAll()
+					}
+				} else {
+					err = decodeBody(dataType, body, out)
+				}
+			}
+			if err == nil && len(bindings) > 0 {
+				err = bindRouteParams(ctx, ptr.Elem(), bindings)
 			}
-			if dataType == Binary {
-				err = msgpack.Unmarshal(body, &m)
+			data := dataValue(ptr, typ)
+			if err == nil && validate {
+				if err := Validate(data); err != nil {
+					writeError(ctx, route.DataType(), err)
+					return
+				}
 			}
-			if len(m) > 0 {
-				data := route.Data
-				mapstructure.Decode(m, &data) // @TODO get rid of this once we have Generics
+			if err == nil && data != nil {
 				ctx.SetUserValue(key, data)
 			}
 		}
 		if err != nil {
-			ctx.SetStatusCode(fasthttp.StatusBadRequest)
-			ctx.WriteString(err.Error())
+			writeError(ctx, route.DataType(), ErrBadRequest.WithMessage(err.Error()))
 			return
 		}
 		next(ctx)
@@ -240,6 +476,6 @@ func replaceRouteMatches(m map[string][]string) func([]byte) []byte {
 	return func(match []byte) []byte {
 		key := match[1:]
 		value := m[string(key)]
-		return []byte(value[0])
+		return []byte(url.PathEscape(value[0]))
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/valyala/fasthttp"
 	"net"
 	"testing"
+	"time"
 )
 
 func tagMiddleware(tag string) fastalice.Constructor {
@@ -36,12 +37,10 @@ func testMethodHandler(path string) RequestHandler {
 
 func routeContractForMethod(method Method, path string) *RouteContract {
 	return &RouteContract{
-		"Test" + string(method),
-		"Test " + string(method) + " Method",
-		method,
-		path,
-		DataHandlerConfig{},
-		nil,
+		Label:       "Test" + string(method),
+		Description: "Test " + string(method) + " Method",
+		Method:      method,
+		Path:        path,
 	}
 }
 
@@ -125,6 +124,14 @@ func TestPut(t *testing.T) {
 	testMethod(t, PUT, "8083")
 }
 
+func TestPatch(t *testing.T) {
+	testMethod(t, PATCH, "10088")
+}
+
+func TestOptions(t *testing.T) {
+	testMethod(t, OPTIONS, "10089")
+}
+
 func TestMiddlewareDataHandlerOrder(t *testing.T) {
 	path := "/middlewares"
 	method := Method(POST)
@@ -267,3 +274,67 @@ func TestFormData(t *testing.T) {
 	assert.Equal(t, path+"[Foo]=foo[Bar]=bar[FooBar]=[foo bar]", string(body), "Body output should be the correct path and data")
 }
 
+
+type FormRoutePayload struct {
+	Foo      string
+	FooBar   []string
+	IntValue int
+	Ratio    *float64
+	Since    time.Time
+	Enabled  bool
+}
+
+func TestFormAndQueryDecoding(t *testing.T) {
+	path := "/form"
+	contract := &RouteContract{
+		Label:             "TypedForm",
+		Method:            POST,
+		Path:              path,
+		DataHandlerConfig: DataHandlerConfig{BodyType: Form},
+		Data:              FormRoutePayload{},
+	}
+	route := &Route{RouteContract: contract, RequestHandler: func(ctx *Context) {
+		data, err := Payload[FormRoutePayload](ctx)
+		if err != nil {
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.WriteString(fmt.Sprintf("%s%v%d/%v/%s/%t", data.Foo, data.FooBar, data.IntValue, *data.Ratio, data.Since.UTC().Format(time.RFC3339), data.Enabled))
+	}}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10084"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{
+		QueryParams: map[string]string{"Ratio": "0.5", "Foo": "query"},
+		Body: map[string]interface{}{
+			"Foo":      "foo",
+			"FooBar":   []string{"foo", "bar"},
+			"IntValue": 42,
+			"Since":    time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC),
+			"Enabled":  true,
+		},
+	})
+	assert.Nil(t, err, "Preparing the request must not return an error")
+
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, "foo[foo bar]42/0.5/2020-10-15T12:00:00Z/true", string(resp.Body()), "Form body and query args must be decoded into Data")
+
+	req.SetRequestURI("http://" + url + path + "?IntValue=notanumber")
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode(), "Invalid values must return a bad request")
+}
@@ -0,0 +1,251 @@
+package lotus
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Schema describes the shape of a payload. It is a subset of JSON Schema compatible with OpenAPI 3 schema objects
+type Schema struct {
+	// Ref references a schema declared on the components of an OpenAPI document
+	Ref string `json:"$ref,omitempty" yaml:"$ref,omitempty" msgpack:"$ref,omitempty"`
+	// Title is the Go type name of named types
+	Title       string             `json:"title,omitempty" yaml:"title,omitempty" msgpack:"title,omitempty"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty" msgpack:"description,omitempty"`
+	Type        string             `json:"type,omitempty" yaml:"type,omitempty" msgpack:"type,omitempty"`
+	Format      string             `json:"format,omitempty" yaml:"format,omitempty" msgpack:"format,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty" yaml:"nullable,omitempty" msgpack:"nullable,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty" msgpack:"properties,omitempty"`
+	// PropertyOrder keeps the declaration order of Properties
+	PropertyOrder        []string `json:"x-order,omitempty" yaml:"x-order,omitempty" msgpack:"x-order,omitempty"`
+	Required             []string `json:"required,omitempty" yaml:"required,omitempty" msgpack:"required,omitempty"`
+	Items                *Schema  `json:"items,omitempty" yaml:"items,omitempty" msgpack:"items,omitempty"`
+	AdditionalProperties *Schema  `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty" msgpack:"additionalProperties,omitempty"`
+	Enum                 []string `json:"enum,omitempty" yaml:"enum,omitempty" msgpack:"enum,omitempty"`
+	Pattern              string   `json:"pattern,omitempty" yaml:"pattern,omitempty" msgpack:"pattern,omitempty"`
+	Minimum              *float64 `json:"minimum,omitempty" yaml:"minimum,omitempty" msgpack:"minimum,omitempty"`
+	Maximum              *float64 `json:"maximum,omitempty" yaml:"maximum,omitempty" msgpack:"maximum,omitempty"`
+	MinLength            *int     `json:"minLength,omitempty" yaml:"minLength,omitempty" msgpack:"minLength,omitempty"`
+	MaxLength            *int     `json:"maxLength,omitempty" yaml:"maxLength,omitempty" msgpack:"maxLength,omitempty"`
+	MinItems             *int     `json:"minItems,omitempty" yaml:"minItems,omitempty" msgpack:"minItems,omitempty"`
+	MaxItems             *int     `json:"maxItems,omitempty" yaml:"maxItems,omitempty" msgpack:"maxItems,omitempty"`
+	// OmitEmpty reports whether the validation rules of a property are skipped for its zero value
+	OmitEmpty bool `json:"x-omitempty,omitempty" yaml:"x-omitempty,omitempty" msgpack:"x-omitempty,omitempty"`
+	// PathParam is the route param a property is bound to by a `lotus:"path=..."` tag
+	PathParam string `json:"x-path-param,omitempty" yaml:"x-path-param,omitempty" msgpack:"x-path-param,omitempty"`
+}
+
+// SchemaOf reflects the Schema of the type of v encoded with dataType. Property names are the ones used by its codec:
+// json tags for JSON, msgpack tags for Binary and field names for forms. Named structs referenced recursively are
+// returned as references and collected on definitions
+func SchemaOf(v interface{}, dataType DataType) (schema *Schema, definitions map[string]*Schema) {
+	if v == nil {
+		return nil, nil
+	}
+	builder := schemaBuilder{
+		dataType:    dataType,
+		definitions: map[string]*Schema{},
+		building:    map[reflect.Type]bool{},
+	}
+	schema = builder.schema(reflect.TypeOf(v))
+	if len(builder.definitions) == 0 {
+		return schema, nil
+	}
+	return schema, builder.definitions
+}
+
+type schemaBuilder struct {
+	dataType    DataType
+	definitions map[string]*Schema
+	building    map[reflect.Type]bool
+	recursive   map[reflect.Type]bool
+}
+
+func (b *schemaBuilder) schema(typ reflect.Type) *Schema {
+	switch typ {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "string", Format: "duration"}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+	if typ.Implements(readerType) {
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Ptr:
+		s := b.schema(typ.Elem())
+		s.Nullable = true
+		return s
+	case reflect.Slice, reflect.Array:
+		if typ.Elem() == fileHeaderType {
+			return &Schema{Type: "array", Items: &Schema{Type: "string", Format: "binary"}}
+		}
+		return &Schema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(typ.Elem())}
+	case reflect.Struct:
+		return b.structSchema(typ)
+	}
+	return &Schema{}
+}
+
+func (b *schemaBuilder) structSchema(typ reflect.Type) *Schema {
+	if b.building[typ] {
+		if b.recursive == nil {
+			b.recursive = map[reflect.Type]bool{}
+		}
+		b.recursive[typ] = true
+		return &Schema{Ref: "#/components/schemas/" + typ.Name()}
+	}
+	b.building[typ] = true
+	defer delete(b.building, typ)
+
+	s := &Schema{Type: "object", Title: typ.Name(), Properties: map[string]*Schema{}}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, ok := fieldName(field, b.dataType)
+		if !ok {
+			continue
+		}
+		property := b.schema(field.Type)
+		if param, ok := fieldTag(field, "path"); ok {
+			property.PathParam = param
+		}
+		rules, omitEmpty, _ := parseValidationRules(field.Tag.Get(validateTagName))
+		property.OmitEmpty = omitEmpty && len(rules) > 0
+		if applyValidation(property, rules) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+		s.PropertyOrder = append(s.PropertyOrder, name)
+	}
+
+	if b.recursive[typ] {
+		b.definitions[typ.Name()] = s
+		return &Schema{Ref: "#/components/schemas/" + typ.Name()}
+	}
+	return s
+}
+
+// fieldName returns the name of a field on payloads encoded with dataType. JSON honors json tags, Binary honors
+// msgpack tags and forms use the field names
+func fieldName(field reflect.StructField, dataType DataType) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	var key string
+	switch dataType {
+	case JSON:
+		key = "json"
+	case Binary:
+		key = "msgpack"
+	default:
+		return field.Name, true
+	}
+	name := field.Name
+	if tag, ok := field.Tag.Lookup(key); ok {
+		tagName, _, _ := strings.Cut(tag, ",")
+		if tagName == "-" {
+			return "", false
+		}
+		if tagName != "" {
+			name = tagName
+		}
+	}
+	return name, true
+}
+
+// applyValidation adds the validation rules to the schema, returning whether the field is required
+func applyValidation(s *Schema, rules []validationRule) (required bool) {
+	for _, rule := range rules {
+		n := rule.n
+		length := int(n)
+		switch rule.name {
+		case "required":
+			required = true
+		case "regex":
+			s.Pattern = rule.param
+		case "enum":
+			s.Enum = rule.enum
+		case "min", "max", "len":
+			switch s.Type {
+			case "integer", "number":
+				if rule.name != "max" {
+					s.Minimum = &n
+				}
+				if rule.name != "min" {
+					s.Maximum = &n
+				}
+			case "array", "object":
+				if rule.name != "max" {
+					s.MinItems = &length
+				}
+				if rule.name != "min" {
+					s.MaxItems = &length
+				}
+			default:
+				if rule.name != "max" {
+					s.MinLength = &length
+				}
+				if rule.name != "min" {
+					s.MaxLength = &length
+				}
+			}
+		}
+	}
+	return required
+}
+
+// hasValidation reports whether the schema, or any schema nested on it, documents validation rules. References are
+// resolved on definitions
+func (s *Schema) hasValidation(definitions map[string]*Schema) bool {
+	return s.declaresValidation(definitions, map[string]bool{})
+}
+
+func (s *Schema) declaresValidation(definitions map[string]*Schema, seen map[string]bool) bool {
+	if s == nil {
+		return false
+	}
+	if s.Ref != "" {
+		name := s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+		return definitions[name].declaresValidation(definitions, seen)
+	}
+	if len(s.Required) > 0 || len(s.Enum) > 0 || s.Pattern != "" || s.Minimum != nil || s.Maximum != nil ||
+		s.MinLength != nil || s.MaxLength != nil || s.MinItems != nil || s.MaxItems != nil {
+		return true
+	}
+	for _, property := range s.Properties {
+		if property.declaresValidation(definitions, seen) {
+			return true
+		}
+	}
+	return s.Items.declaresValidation(definitions, seen) || s.AdditionalProperties.declaresValidation(definitions, seen)
+}
+
+// openAPIPath converts the route params of a path to the OpenAPI notation, e.g. "/users/:id" to "/users/{id}"
+func openAPIPath(path string) string {
+	return routerParamReg.ReplaceAllStringFunc(path, func(param string) string {
+		return "{" + param[1:] + "}"
+	})
+}
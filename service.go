@@ -1,6 +1,8 @@
 package lotus
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/brunvieira/fastalice"
@@ -9,6 +11,9 @@ import (
 	"log"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type protocol string
@@ -24,7 +29,11 @@ const (
 	DefaultNamespace = ""
 	// Default Version is the version used if not initialized.
 	DefaultVersion = "v0"
+	// DefaultShutdownTimeout is the time Run waits for in-flight requests when its context is done
+	DefaultShutdownTimeout = 10 * time.Second
+)
 
+const (
 	// Protocols
 
 	// HTTP protocol
@@ -34,7 +43,7 @@ const (
 
 	// Errors
 
-	// RouteNotFoundError error when the service can't find a route
+	// RouteNotFoundError is the message of ErrRouteNotFound
 	RouteNotFoundError string = "route not found"
 )
 
@@ -42,14 +51,33 @@ type ServiceStatus struct {
 	IsRunning        bool
 	Address          net.Addr
 	RegisteredRoutes int
+	// IsDraining is true while the service is shutting down and waiting for in-flight requests
+	IsDraining bool
+	// OpenConnections is the number of connections currently open on the service
+	OpenConnections int
+	// Circuits is the state of the circuits of the CircuitBreakers used to call subscribed services
+	Circuits []CircuitStatus
+	// Pools reports the connections to each instance of the subscribed services
+	Pools []PoolStatus
+	// Panics is the number of panics recovered while serving requests
+	Panics uint64
 }
 
+// ShutdownHook is a function executed by Service.Shutdown once in-flight requests are drained
+type ShutdownHook func(ctx context.Context) error
+
 // Service providers are constructs able to start, stop and show it's current health (heartbeat)
 type ServiceProvider interface {
-	// Start inits the main process executed by the service
-	Start()
+	// Start inits the main process executed by the service. It blocks until the service is stopped
+	Start() error
+	// Run starts the service and shuts it down once ctx is done
+	Run(ctx context.Context) error
+	// Ready returns a channel closed once the service is able to receive requests
+	Ready() <-chan struct{}
 	// Stop terminates all processes related to the service
 	Stop() error
+	// Shutdown gracefully terminates the service, waiting for in-flight requests until ctx is done
+	Shutdown(ctx context.Context) error
 	// Status returns information about the health of the service
 	Status() *ServiceStatus
 }
@@ -57,21 +85,26 @@ type ServiceProvider interface {
 // ServiceContract holds the Contract description of a service
 type ServiceContract struct {
 	// Name of the service. Used as a identifier for the service
-	Label string
+	Label string `json:"label" yaml:"label"`
 	// Description of the service. Intended to be a Short text describing the functionalities of the service
-	Description string
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 	// Protocol is the protocol which the serve will use. The current options are: HTTP, HTTPS or TCP. Defaults to HTTP
-	Protocol protocol
+	Protocol protocol `json:"protocol,omitempty" yaml:"protocol,omitempty"`
 	// Host of the service. Can be an IPV4 or IPV6 address. Defaults to "localhost"
-	Host string
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
 	// Namespace of the service. The unique namespace which the service will be delivered upon. Defaults to "/"
-	Namespace string
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 	// Port of the service listener
-	Port int
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
 	// A version identifier for the service. Defaults to "v0"
-	Version string
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
 	// RoutesContracts is an array of RouteContract used to define the Routes on the contract
-	RoutesContracts []RouteContract
+	RoutesContracts []RouteContract `json:"routes,omitempty" yaml:"routes,omitempty"`
+	// Environments holds the address overrides of each environment the service is deployed upon. They are applied
+	// by WithEnvironment
+	Environments map[string]ServiceOverride `json:"environments,omitempty" yaml:"environments,omitempty"`
+	// ClientOptions tunes the connections of the ServiceClients of services subscribing to this one
+	ClientOptions *ClientOptions `json:"client,omitempty" yaml:"client,omitempty"`
 }
 
 // RouteContractByLabel returns the route contract for the given label
@@ -149,19 +182,24 @@ func (sc *ServiceContract) routeByLabel(label string) (*RouteContract, error) {
 			return &r, nil
 		}
 	}
-	return nil, errors.New(RouteNotFoundError)
+	return nil, ErrRouteNotFound.WithMessage(RouteNotFoundError + ": " + label)
 }
 
 func (sc *ServiceContract) RouteUrl(label string) (string, error) {
+	return sc.routeUrlAt(sc.address(), label)
+}
+
+// routeUrlAt returns the url of the route on the instance of the service listening on address
+func (sc *ServiceContract) routeUrlAt(address, label string) (string, error) {
 	r, err := sc.routeByLabel(label)
 	if err != nil {
 		return "", err
 	}
 	w := strings.Builder{}
-	w.Grow(len(sc.protocol()) + 3 + len(sc.address()) + len(sc.Suffix()) + len(r.Path))
+	w.Grow(len(sc.protocol()) + 3 + len(address) + len(sc.Suffix()) + len(r.Path))
 	w.WriteString(sc.protocol())
 	w.WriteString("://")
-	w.WriteString(sc.address())
+	w.WriteString(address)
 	w.WriteString(sc.Suffix())
 	w.WriteString(r.Path)
 	return w.String(), nil
@@ -170,12 +208,62 @@ func (sc *ServiceContract) RouteUrl(label string) (string, error) {
 // Service is a Service Provider that starts itself and serves declared routes over a self created router
 type Service struct {
 	*ServiceContract
+	// ShutdownTimeout is the time Run waits for in-flight requests when its context is done. Defaults to
+	// DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
+	// TLSConfig is used to serve HTTPS services. Set ClientCAs and ClientAuth to require client certificates
+	TLSConfig *tls.Config
+	// CertFile and KeyFile are PEM files holding the certificate used to serve HTTPS services
+	CertFile string
+	KeyFile  string
+	// ClientTLSConfig is used by the ServiceClients of subscribed HTTPS services
+	ClientTLSConfig *tls.Config
+	// Resolver is used by the ServiceClients of subscribed services to find their instances
+	Resolver Resolver
+	// CircuitBreaker is used by the ServiceClients of subscribed services. It keeps a circuit per subscribed service
+	// and its state is reported by Status
+	CircuitBreaker *CircuitBreaker
+	// ConfigureClient is called with the ServiceClient of each subscribed service once it's created, e.g. to set its
+	// Balancer and HealthCheck
+	ConfigureClient func(client *ServiceClient)
+	// DisableRecovery lets panics of route handlers and middlewares crash the fasthttp worker. By default they are
+	// recovered, reported to PanicReporter and answered with ErrInternal
+	DisableRecovery bool
+	// PanicReporter receives the recovered panics. Defaults to LogPanic
+	PanicReporter PanicReporter
+	// ExposeContract serves the service description, including registered routes and payload schemas, on
+	// ContractPath as JSON or msgpack
+	ExposeContract bool
 	// private addr field. Holds a reference to the service addr
 	addr string
 	// private router field. Holds a reference to the router
 	router *fasthttprouter.Router
 	// private listener field. Holds a reference to the listener
 	listener net.Listener
+	// private server field. Holds a reference to the server serving the router
+	server *fasthttp.Server
+	// mu guards the listener, server, conns and draining fields
+	mu sync.Mutex
+	// conns holds the state of the open connections
+	conns map[net.Conn]fasthttp.ConnState
+	// draining is true while the service is shutting down
+	draining bool
+	// middlewares are executed before the middlewares of every route
+	middlewares []fastalice.Constructor
+	// contextMiddlewares are executed before the context middlewares of every route
+	contextMiddlewares []Middleware
+	// groups are the route groups added with Group
+	groups []*RouteGroup
+	// panics counts the recovered panics
+	panics uint64
+	// shutdownHooks are executed by Shutdown once the service is drained
+	shutdownHooks []ShutdownHook
+	// errorMappings are the errors mapped with MapError, answered by Context.Error on every route
+	errorMappings []errorMapping
+	// ready is closed once the listener is bound
+	ready chan struct{}
+	// setupErrors holds the errors found while setting up routes. They are returned by Start
+	setupErrors []error
 	// routes is an array of Route from the Service. They are validate against the RoutesContracts from the ServiceContract
 	routes []*Route
 	// serviceClients holds references to ServiceClients this service subscribe to
@@ -195,41 +283,208 @@ values:
 			Host: "myhost.com",
 			Namespace: "example",
 		}
+
+Start returns an error if a route wasn't properly set up or the listener can't be bound. Otherwise it blocks until the
+service is stopped, returning nil after Stop or Shutdown.
 */
-func (service *Service) Start() {
-	service.validateRoutes()
+func (service *Service) Start() error {
+	if err := service.validateRoutes(); err != nil {
+		return err
+	}
 	service.createRouter()
 	service.startServiceClients()
 	service.startRoutes()
-	service.startListening()
+	stopHealthChecks := service.startHealthChecks()
+	defer stopHealthChecks()
+	return service.startListening()
 }
 
+// Run starts the service and blocks until ctx is done, gracefully shutting it down afterwards. Errors starting the
+// service are returned immediately
+func (service *Service) Run(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- service.Start()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-service.Ready():
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), service.shutdownTimeout())
+	defer cancel()
+	err := service.Shutdown(shutdownCtx)
+	if startErr := <-errc; err == nil {
+		err = startErr
+	}
+	return err
+}
+
+// Ready returns a channel closed once the service listener is bound and the service is able to receive requests
+func (service *Service) Ready() <-chan struct{} {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.readyChan()
+}
+
+func (service *Service) readyChan() chan struct{} {
+	if service.ready == nil {
+		service.ready = make(chan struct{})
+	}
+	return service.ready
+}
+
+func (service *Service) shutdownTimeout() time.Duration {
+	if service.ShutdownTimeout > 0 {
+		return service.ShutdownTimeout
+	}
+	return DefaultShutdownTimeout
+}
+
+// Stop terminates the service right away. It stops accepting connections and closes the open ones, idle keep-alive
+// connections included, waiting only for the handlers already running. ShutdownHooks aren't executed, use Shutdown
+// to drain in-flight requests
 func (service *Service) Stop() error {
-	if service.listener == nil {
+	service.mu.Lock()
+	server := service.server
+	if server == nil || service.listener == nil {
+		service.mu.Unlock()
 		return errors.New("service connection not found")
 	}
-	service.listener.Close()
+	// connections accepted until the listener is closed are closed once idle
+	service.draining = true
+	service.listener = nil
+	service.ready = nil
+	service.mu.Unlock()
+
+	service.closeConns()
+	err := server.Shutdown()
+
+	service.mu.Lock()
+	service.draining = false
+	service.mu.Unlock()
 	log.Println("Service", service.Label, "stopped...")
-	return nil
+	return err
 }
 
-func (service *Service) Status() *ServiceStatus {
-	if service.listener == nil {
-		return &ServiceStatus{
-			IsRunning:        false,
-			Address:          nil,
-			RegisteredRoutes: len(service.routes),
+// Shutdown gracefully terminates the service. It stops accepting connections, closes idle keep-alive connections
+// and waits for in-flight requests to finish. If ctx is done before that, the remaining connections are closed and
+// ctx error is returned. The registered ShutdownHooks are executed afterwards
+func (service *Service) Shutdown(ctx context.Context) error {
+	service.mu.Lock()
+	server := service.server
+	if server == nil || service.listener == nil {
+		service.mu.Unlock()
+		return errors.New("service connection not found")
+	}
+	service.draining = true
+	for c, state := range service.conns {
+		if state == fasthttp.StateIdle || state == fasthttp.StateNew {
+			c.Close()
+		}
+	}
+	service.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		service.closeConns()
+	}
+
+	for _, hook := range service.shutdownHooks {
+		if hookErr := hook(ctx); hookErr != nil && err == nil {
+			err = hookErr
 		}
 	}
-	return &ServiceStatus{
-		IsRunning:        true,
-		Address:          service.listener.Addr(),
+
+	service.mu.Lock()
+	service.draining = false
+	service.listener = nil
+	service.ready = nil
+	service.mu.Unlock()
+	log.Println("Service", service.Label, "shut down...")
+	return err
+}
+
+// OnShutdown registers hooks executed by Shutdown once in-flight requests are drained
+func (service *Service) OnShutdown(hooks ...ShutdownHook) {
+	service.shutdownHooks = append(service.shutdownHooks, hooks...)
+}
+
+func (service *Service) Status() *ServiceStatus {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	status := &ServiceStatus{
 		RegisteredRoutes: len(service.routes),
+		IsDraining:       service.draining,
+		OpenConnections:  len(service.conns),
+		Panics:           atomic.LoadUint64(&service.panics),
+	}
+	if service.listener != nil {
+		status.IsRunning = true
+		status.Address = service.listener.Addr()
+	}
+	breakers := map[*CircuitBreaker]bool{}
+	for _, client := range service.serviceClients {
+		if client.Pool != nil {
+			for _, pool := range client.Pool.Status() {
+				pool.Service = client.Label
+				status.Pools = append(status.Pools, pool)
+			}
+		}
+		if client.CircuitBreaker != nil && !breakers[client.CircuitBreaker] {
+			breakers[client.CircuitBreaker] = true
+			status.Circuits = append(status.Circuits, client.CircuitBreaker.Status()...)
+		}
+	}
+	return status
+}
+
+// trackConn is the server ConnState hook. It keeps the state of open connections and closes the ones that become
+// idle while the service is draining
+func (service *Service) trackConn(c net.Conn, state fasthttp.ConnState) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	switch state {
+	case fasthttp.StateClosed, fasthttp.StateHijacked:
+		delete(service.conns, c)
+	default:
+		if service.conns == nil {
+			service.conns = map[net.Conn]fasthttp.ConnState{}
+		}
+		service.conns[c] = state
+		if state == fasthttp.StateIdle && service.draining {
+			c.Close()
+		}
+	}
+}
+
+func (service *Service) closeConns() {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	for c := range service.conns {
+		c.Close()
 	}
 }
 
 // SetupRoute searches for a route contract identified by label, creates a Route, add to it the endpoint and the
-// middlewares and put it on the Routes array returning the newly created routes
+// middlewares and put it on the Routes array returning the newly created routes. If the label is not found on the
+// contract SetupRoute returns nil and the error is reported by Start
 func (service *Service) SetupRoute(
 	label string,
 	endpoint RequestHandler,
@@ -237,13 +492,16 @@ func (service *Service) SetupRoute(
 	dataHandler fastalice.Constructor,
 ) *Route {
 	routeContract := service.routeContract(label)
-	service.testRouteContractExists(routeContract, label, true)
+	if err := routeContractExists(routeContract, label); err != nil {
+		service.setupErrors = append(service.setupErrors, err)
+		return nil
+	}
 	route := Route{
-		routeContract,
-		endpoint,
-		middlewares,
-		dataHandler,
-		[]ServiceClient{},
+		RouteContract:  routeContract,
+		RequestHandler: endpoint,
+		Middlewares:    middlewares,
+		DataHandler:    dataHandler,
+		serviceClients: []ServiceClient{},
 	}
 	service.AddRoute(&route)
 	return &route
@@ -255,7 +513,23 @@ func (service *Service) AddRoute(route *Route) {
 	service.routes = routes
 }
 
-func (service *Service) validateRoutes() {
+func (service *Service) validateRoutes() error {
+	if len(service.setupErrors) > 0 {
+		return service.setupErrors[0]
+	}
+	for _, route := range service.routes {
+		if err := route.validateMethod(); err != nil {
+			return err
+		}
+	}
+	for _, routeContract := range service.RoutesContracts {
+		if err := routeContract.validateMethod(); err != nil {
+			return err
+		}
+		if err := validationRulesError(routeContract.Data); err != nil {
+			return fmt.Errorf("route %s: %w", routeContract.Label, err)
+		}
+	}
 	routeDescriptions := service.RoutesContracts
 	for _, desc := range routeDescriptions {
 		var routeContract *RouteContract
@@ -264,21 +538,18 @@ func (service *Service) validateRoutes() {
 				routeContract = c.RouteContract
 			}
 		}
-		service.testRouteContractExists(routeContract, desc.Label, false)
+		if err := routeContractExists(routeContract, desc.Label); err != nil {
+			log.Printf("Warning: %s", err)
+		}
 	}
+	return nil
 }
 
-func (service *Service) testRouteContractExists(
-	routeContract *RouteContract,
-	label string,
-	shouldPanic bool,
-) {
+func routeContractExists(routeContract *RouteContract, label string) error {
 	if routeContract == nil {
-		if shouldPanic {
-			panic("Route for " + label + " not found")
-		}
-		log.Printf("Warning: Route for " + label + " not found")
+		return fmt.Errorf("route for %s not found", label)
 	}
+	return nil
 }
 
 func (service *Service) routeContract(label string) *RouteContract {
@@ -291,8 +562,13 @@ func (service *Service) routeContract(label string) *RouteContract {
 }
 
 func (service *Service) createRouter() {
-	if service.router == nil {
-		service.router = fasthttprouter.New()
+	service.router = fasthttprouter.New()
+	service.router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		writeError(ctx, DefaultBodyDataType, ErrRouteNotFound)
+	}
+	service.router.MethodNotAllowed = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		ctx.SetBodyString(fasthttp.StatusMessage(fasthttp.StatusMethodNotAllowed))
 	}
 }
 
@@ -300,32 +576,174 @@ func (service *Service) startServiceClients() {
 	if service.subscriptions == nil || len(service.subscriptions) == 0 {
 		return
 	}
-	if service.serviceClients == nil || len(service.serviceClients) == 0 {
-		service.serviceClients = []ServiceClient{}
-	}
-	for _, sub := range service.subscriptions {
-		client := ServiceClient{&sub}
+	service.serviceClients = []ServiceClient{}
+	for i := range service.subscriptions {
+		client := ServiceClient{
+			ServiceContract: &service.subscriptions[i],
+			TLSConfig:       service.ClientTLSConfig,
+			Resolver:        service.Resolver,
+			CircuitBreaker:  service.CircuitBreaker,
+			Pool:            &ClientPool{},
+		}
+		if options := service.subscriptions[i].ClientOptions; options != nil {
+			client.Pool.ClientOptions = *options
+		}
+		if service.ConfigureClient != nil {
+			service.ConfigureClient(&client)
+		}
 		service.serviceClients = append(service.serviceClients, client)
 	}
 }
 
+// startHealthChecks runs the HealthCheck of every service client until the returned function is called
+func (service *Service) startHealthChecks() context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := range service.serviceClients {
+		go service.serviceClients[i].RunHealthCheck(ctx)
+	}
+	return cancel
+}
+
 func (service *Service) startRoutes() {
 	for _, route := range service.routes {
+		route.serviceClients = []ServiceClient{}
 		for _, client := range service.serviceClients {
 			route.addServiceClient(client)
 		}
-		route.startRoute(service.router, service.Suffix())
+		route.errorMappings = service.errorMappings
+		route.recovery = nil
+		if !service.DisableRecovery {
+			route.recovery = service.recoverer(route.Label, route.DataType())
+		}
+		route.serviceMiddlewares, route.serviceContextMiddlewares = service.routeMiddlewares(route)
+	}
+	// routes with an explicit method go first so ANY routes only take the remaining methods
+	for _, route := range service.routes {
+		if route.method() != ANY {
+			route.startRoute(service.router, service.Suffix())
+		}
+	}
+	for _, route := range service.routes {
+		if route.method() == ANY {
+			route.startRoute(service.router, service.Suffix())
+		}
 	}
+	for _, route := range service.routes {
+		route.startImplicitRoutes(service.router, service.Suffix())
+	}
+	if service.ExposeContract {
+		service.router.GET(service.Suffix()+ContractPath, service.recovered("Contract", JSON, service.serveContract))
+	}
+	service.router.NotFound = service.recovered("NotFound", DefaultBodyDataType, service.router.NotFound)
+	service.router.MethodNotAllowed = service.recovered("MethodNotAllowed", DefaultBodyDataType, service.router.MethodNotAllowed)
 }
 
-func (service *Service) startListening() {
+func (service *Service) startListening() error {
+	var tlsConfig *tls.Config
+	if service.protocol() == HTTPS {
+		config, err := service.serverTLSConfig()
+		if err != nil {
+			return err
+		}
+		tlsConfig = config
+	}
+
 	ln, err := net.Listen("tcp", service.address())
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
 	}
+	server := &fasthttp.Server{
+		Handler:      service.router.Handler,
+		Name:         service.Label,
+		ConnState:    service.trackConn,
+		ErrorHandler: service.readError,
+	}
+	if service.limitsBodies() {
+		server.HeaderReceived = service.headerReceived
+	}
+	service.mu.Lock()
 	service.listener = ln
+	service.server = server
+	close(service.readyChan())
+	service.mu.Unlock()
 	log.Printf("Serving at: %s", service.address())
-	fasthttp.Serve(ln, service.router.Handler)
+
+	err = server.Serve(ln)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if service.listener == nil {
+		// the service was stopped
+		return nil
+	}
+	return err
+}
+
+// limitsBodies reports whether any route declares a MaxBodySize
+func (service *Service) limitsBodies() bool {
+	for _, route := range service.routes {
+		if route.DataHandlerConfig.MaxBodySize > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// headerReceived limits the body read for the request to the MaxBodySize of its route. The limit applies before
+// the body is read, so requests over it are rejected without being buffered
+func (service *Service) headerReceived(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	route := service.routeOf(header)
+	if route == nil {
+		return fasthttp.RequestConfig{}
+	}
+	return fasthttp.RequestConfig{MaxRequestBodySize: route.DataHandlerConfig.MaxBodySize}
+}
+
+// readError answers requests fasthttp failed to read
+func (service *Service) readError(ctx *fasthttp.RequestCtx, err error) {
+	if err == fasthttp.ErrBodyTooLarge {
+		dataType := DefaultBodyDataType
+		if route := service.routeOf(&ctx.Request.Header); route != nil {
+			dataType = route.DataType()
+		}
+		writeError(ctx, dataType, ErrPayloadTooLarge)
+		return
+	}
+	if _, ok := err.(*fasthttp.ErrSmallBuffer); ok {
+		ctx.Error("Too big request header", fasthttp.StatusRequestHeaderFieldsTooLarge)
+	} else if netErr, ok := err.(*net.OpError); ok && netErr.Timeout() {
+		ctx.Error("Request timeout", fasthttp.StatusRequestTimeout)
+	} else {
+		ctx.Error("Error when parsing request", fasthttp.StatusBadRequest)
+	}
+}
+
+// routeOf returns the route matching the method and path of header or nil. Like the router, routes with an explicit
+// method take precedence over ANY routes
+func (service *Service) routeOf(header *fasthttp.RequestHeader) *Route {
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+	if uri.Parse(header.Host(), header.RequestURI()) != nil {
+		return nil
+	}
+	path := string(uri.Path())
+	method := Method(header.Method())
+	var anyRoute *Route
+	for _, route := range service.routes {
+		if !matchesPattern(service.Suffix()+route.Path, path) {
+			continue
+		}
+		if route.method() == method {
+			return route
+		}
+		if route.method() == ANY && anyRoute == nil {
+			anyRoute = route
+		}
+	}
+	return anyRoute
 }
 
 func (service *Service) SubscribeToService(sub ServiceContract) {
@@ -1,8 +1,11 @@
 package lotus
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -79,7 +82,8 @@ func TestFindLabelOnContract(t *testing.T) {
 func TestSetupRoute(t *testing.T) {
 	service := Service{ServiceContract: &EchoServiceContract}
 
-	shouldPanic(t, func() { service.SetupRoute("EmptyRoute", echo, nil, nil) })
+	emptyRoute := service.SetupRoute("EmptyRoute", echo, nil, nil)
+	assert.Nil(t, emptyRoute, "SetupRoute should return a nil route for a label not found on the contract")
 	notFoundRouteUrl, err := service.RouteUrl("NotFoundRoute")
 	assert.Empty(t, notFoundRouteUrl, "A non found route should return an empty url")
 
@@ -96,9 +100,12 @@ func TestSetupRoute(t *testing.T) {
 	err = service.Stop()
 	assert.NotNil(t, err, "Stopping a non started service should return an error")
 
+	err = service.Start()
+	assert.NotNil(t, err, "Starting a service with a route not found on the contract should return an error")
+
 }
 
-func TestPanicWhenServicePortIsTaken(t *testing.T) {
+func TestErrorWhenServicePortIsTaken(t *testing.T) {
 	service := Service{ServiceContract: &EchoServiceContract}
 	simpleEchoRoute := service.SetupRoute("SimpleEcho", echo, nil, nil)
 	assert.NotNil(t, simpleEchoRoute, "SetupRoute should return a non nil route for 'SimpleEcho' value")
@@ -116,13 +123,11 @@ func TestPanicWhenServicePortIsTaken(t *testing.T) {
 	assert.NotNil(t, anotherSimpleEchoRoute, "SetupRoute should return a non nil route for 'SimpleEcho' value")
 	assert.NotNil(t, anotherPostEchoRoute, "SetupRoute should return a non nil route for 'PostEcho' value")
 
-	go service.Start()
+	startService(t, &service)
 	defer service.Stop()
 
-	shouldPanic(t, func() {
-		anotherService.Start()
-		anotherService.Stop()
-	})
+	err := anotherService.Start()
+	assert.NotNil(t, err, "Starting a service on a port already taken should return an error")
 }
 
 func TestStartService(t *testing.T) {
@@ -140,11 +145,9 @@ func TestStartService(t *testing.T) {
 	url, err := service.RouteUrl(SimpleEchoRouteContract.Label)
 	assert.Nil(t, err, "Service must build a valid url for a route")
 
-	go service.Start()
+	startService(t, &service)
 	defer service.Stop()
 
-	time.Sleep(2 * time.Second)
-
 	resp := testRequestToHandler(t, GET, url, nil, "Start", fasthttp.StatusOK)
 	defer fasthttp.ReleaseResponse(resp)
 
@@ -153,8 +156,252 @@ func TestStartService(t *testing.T) {
 	assert.Equal(t, len(service.routes), status.RegisteredRoutes, "Service must have same number of routes registered")
 }
 
-func shouldPanic(t *testing.T, f func()) {
-	defer func() { recover() }()
-	f()
-	t.Errorf("should have panicked")
+var (
+	SlowRouteContract = RouteContract{
+		Label: "Slow",
+		Path:  "/slow",
+	}
+	ShutdownServiceContract = ServiceContract{
+		Label:           "ShutdownService",
+		Port:            10087,
+		RoutesContracts: []RouteContract{SlowRouteContract},
+	}
+)
+
+func TestShutdown(t *testing.T) {
+	service := Service{ServiceContract: &ShutdownServiceContract}
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	service.SetupRoute("Slow", func(ctx *Context) {
+		started <- struct{}{}
+		<-release
+		ctx.WriteString("done")
+	}, nil, nil)
+
+	var hookCalled bool
+	service.OnShutdown(func(ctx context.Context) error {
+		hookCalled = true
+		return nil
+	})
+
+	startService(t, &service)
+
+	url, _ := service.RouteUrl("Slow")
+	result := make(chan int, 1)
+	go func() {
+		resp := testRequestToHandler(t, GET, url, nil, "Shutdown", fasthttp.StatusOK)
+		result <- resp.StatusCode()
+		fasthttp.ReleaseResponse(resp)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- service.Shutdown(ctx)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, service.Status().IsDraining, "Service must be draining while requests are in-flight")
+	close(release)
+
+	assert.Equal(t, fasthttp.StatusOK, <-result, "In-flight requests must be completed")
+	assert.Nil(t, <-shutdown, "Shutdown must not return an error when requests are drained")
+	assert.True(t, hookCalled, "Shutdown hooks must be executed")
+
+	status := service.Status()
+	assert.False(t, status.IsRunning, "Service must not be running after shutdown")
+	assert.False(t, status.IsDraining, "Service must not be draining after shutdown")
+	assert.NotNil(t, service.Shutdown(context.Background()), "Shutting down a stopped service must return an error")
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	service := Service{ServiceContract: &ShutdownServiceContract}
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	service.SetupRoute("Slow", func(ctx *Context) {
+		started <- struct{}{}
+		<-release
+	}, nil, nil)
+
+	startService(t, &service)
+
+	url, _ := service.RouteUrl("Slow")
+	go func() {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.SetRequestURI(url)
+		fasthttp.Do(req, nil)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := service.Shutdown(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "Shutdown must return when the deadline expires")
+}
+
+var StopServiceContract = ServiceContract{
+	Label:           "StopService",
+	Port:            10111,
+	RoutesContracts: []RouteContract{SimpleEchoRouteContract},
+}
+
+func TestStop(t *testing.T) {
+	service := Service{ServiceContract: &StopServiceContract}
+	service.SetupRoute("SimpleEcho", echo, nil, nil)
+	startService(t, &service)
+
+	url, _ := service.RouteUrl("SimpleEcho")
+	client := &fasthttp.HostClient{Addr: service.address()}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(url)
+	assert.Nil(t, client.Do(req, resp))
+	assert.Equal(t, 1, service.Status().OpenConnections, "The keep-alive connection must be left open")
+
+	assert.Nil(t, service.Stop())
+	assert.Eventually(t, func() bool {
+		return service.Status().OpenConnections == 0
+	}, time.Second, 10*time.Millisecond, "Stop must close the open connections")
+	err := client.Do(req, resp)
+	assert.True(t, errors.Is(err, syscall.ECONNREFUSED), "Keep-alive connections must not be served once stopped, got %v", err)
+	assert.NotNil(t, service.Stop(), "Stopping a stopped service must return an error")
+}
+
+func TestRun(t *testing.T) {
+	service := Service{ServiceContract: &ShutdownServiceContract}
+	service.SetupRoute("Slow", echo, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- service.Run(ctx)
+	}()
+	<-service.Ready()
+
+	url, _ := service.RouteUrl("Slow")
+	resp := testRequestToHandler(t, GET, url, nil, "Run", fasthttp.StatusOK)
+	fasthttp.ReleaseResponse(resp)
+
+	cancel()
+	assert.Nil(t, <-result, "Run must return nil once its context is done")
+	assert.False(t, service.Status().IsRunning, "Service must not be running after Run returns")
+
+	anotherService := Service{ServiceContract: &ShutdownServiceContract}
+	anotherService.SetupRoute("NotFound", echo, nil, nil)
+	assert.NotNil(t, anotherService.Run(context.Background()), "Run must return start errors")
+}
+
+var (
+	ItemsRouteContract = RouteContract{
+		Label: "Items",
+		Path:  "/items",
+	}
+	AnyRouteContract = RouteContract{
+		Label:  "Any",
+		Method: ANY,
+		Path:   "/any",
+	}
+	PostAnyRouteContract = RouteContract{
+		Label:  "PostAny",
+		Method: POST,
+		Path:   "/any",
+	}
+	InvalidMethodRouteContract = RouteContract{
+		Label:  "InvalidMethod",
+		Method: "FETCH",
+		Path:   "/invalid",
+	}
+	MethodsServiceContract = ServiceContract{
+		Label: "MethodsService",
+		Port:  10090,
+		RoutesContracts: []RouteContract{
+			ItemsRouteContract,
+			AnyRouteContract,
+			PostAnyRouteContract,
+		},
+	}
+	InvalidMethodsServiceContract = ServiceContract{
+		Label:           "InvalidMethodsService",
+		Port:            10090,
+		RoutesContracts: []RouteContract{ItemsRouteContract, InvalidMethodRouteContract},
+	}
+)
+
+func TestMethods(t *testing.T) {
+	invalidService := Service{ServiceContract: &InvalidMethodsServiceContract}
+	invalidService.SetupRoute("InvalidMethod", echo, nil, nil)
+	assert.NotNil(t, invalidService.Start(), "Starting a service with an unsupported method should return an error")
+
+	notSetUp := Service{ServiceContract: &InvalidMethodsServiceContract}
+	notSetUp.SetupRoute("Items", echo, nil, nil)
+	assert.NotNil(t, notSetUp.Start(), "Contract routes with an unsupported method must be reported even when not set up")
+
+	service := Service{ServiceContract: &MethodsServiceContract}
+	service.SetupRoute("Items", echo, nil, nil)
+	service.SetupRoute("Any", func(ctx *Context) { ctx.WriteString("any") }, nil, nil)
+	service.SetupRoute("PostAny", func(ctx *Context) { ctx.WriteString("post") }, nil, nil)
+
+	startService(t, &service)
+	defer service.Stop()
+
+	itemsUrl, _ := service.RouteUrl("Items")
+	resp := testRequestToHandler(t, HEAD, itemsUrl, nil, "HEAD", fasthttp.StatusOK)
+	assert.Empty(t, resp.Body(), "HEAD requests to GET routes must not return a body")
+	fasthttp.ReleaseResponse(resp)
+
+	resp = testRequestToHandler(t, OPTIONS, itemsUrl, nil, "OPTIONS", fasthttp.StatusOK)
+	assert.Contains(t, string(resp.Header.Peek("Allow")), GET, "OPTIONS requests must list the allowed methods")
+	fasthttp.ReleaseResponse(resp)
+
+	anyUrl, _ := service.RouteUrl("Any")
+	for _, method := range []Method{GET, PUT, PATCH, DELETE} {
+		resp = testRequestToHandler(t, method, anyUrl, nil, string(method), fasthttp.StatusOK)
+		assert.Equal(t, "any", string(resp.Body()), "ANY routes must answer every method")
+		fasthttp.ReleaseResponse(resp)
+	}
+	resp = testRequestToHandler(t, POST, anyUrl, nil, "POST", fasthttp.StatusOK)
+	assert.Equal(t, "post", string(resp.Body()), "Routes with explicit methods must take precedence over ANY routes")
+	fasthttp.ReleaseResponse(resp)
+}
+
+// startService starts the service on a new goroutine and waits until it's ready
+func startService(t *testing.T, service *Service) {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- service.Start()
+	}()
+	select {
+	case <-service.Ready():
+	case err := <-errc:
+		t.Fatal(err)
+	}
+}
+
+func TestRouteOf(t *testing.T) {
+	anyUpload := RouteContract{Label: "AnyUpload", Method: ANY, Path: "/uploads/:kind", DataHandlerConfig: DataHandlerConfig{MaxBodySize: 16}}
+	postUpload := RouteContract{Label: "PostUpload", Method: POST, Path: "/uploads/:kind", DataHandlerConfig: DataHandlerConfig{MaxBodySize: 1024}}
+	contract := ServiceContract{Label: "Uploads", RoutesContracts: []RouteContract{anyUpload, postUpload}}
+	service := Service{ServiceContract: &contract}
+	service.SetupRoute("AnyUpload", echo, nil, nil)
+	service.SetupRoute("PostUpload", echo, nil, nil)
+
+	header := &fasthttp.RequestHeader{}
+	header.SetMethod("POST")
+	header.SetRequestURI(contract.Suffix() + "/uploads/files?size=large")
+	assert.Equal(t, "PostUpload", service.routeOf(header).Label, "Routes with an explicit method must take precedence over ANY routes")
+	assert.Equal(t, 1024, service.headerReceived(header).MaxRequestBodySize)
+
+	header.SetRequestURI("http://localhost:8000" + contract.Suffix() + "/uploads/files")
+	assert.Equal(t, "PostUpload", service.routeOf(header).Label, "Absolute request targets must match on their path")
+
+	header.SetMethod("PUT")
+	assert.Equal(t, "AnyUpload", service.routeOf(header).Label)
+	header.SetRequestURI(contract.Suffix() + "/downloads/files")
+	assert.Nil(t, service.routeOf(header))
 }
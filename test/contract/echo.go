@@ -21,7 +21,7 @@ var PostEchoRouteContract = lotus.RouteContract{
 var EchoServiceContract = lotus.ServiceContract{
 	Label:     "EchoService",
 	Host:      "localhost",
-	Port:      8080,
+	Port:      9080,
 	Namespace: "echo_service",
 	RoutesContracts: []lotus.RouteContract{
 		SimpleEchoRouteContract,
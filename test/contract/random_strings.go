@@ -17,7 +17,7 @@ var (
 		Description: "Service that generates random strings",
 		Host:        "localhost",
 		Namespace:   "random_strings",
-		Port:        8081,
+		Port:        9081,
 		RoutesContracts: []lotus.RouteContract{
 			RandomStringsRouteContract,
 		},
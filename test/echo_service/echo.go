@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/brunvieira/lotus"
 	"github.com/brunvieira/lotus/test/contract"
-	"github.com/valyala/fasthttp"
 )
 
 func echo(ctx *lotus.Context) {
@@ -12,9 +11,8 @@ func echo(ctx *lotus.Context) {
 }
 func randomString(ctx *lotus.Context) {
 	c := ctx.ServiceClient(contract.RandomStringsServiceContract)
-	resp, err := c.SendRequest(contract.RandomStringsRouteContract, lotus.ServiceRequest{})
-	defer fasthttp.ReleaseResponse(resp)
-	if err == nil {
-		ctx.Write(resp.Body())
+	var random []byte
+	if err := c.Call(ctx, contract.RandomStringsRouteContract, lotus.ServiceRequest{}, &random); err == nil {
+		ctx.Write(random)
 	}
 }
@@ -3,6 +3,7 @@ package echo_service
 import (
 	"github.com/brunvieira/lotus"
 	"github.com/brunvieira/lotus/test/contract"
+	"log"
 )
 
 var EchoService = lotus.Service{ServiceContract: &contract.EchoServiceContract}
@@ -11,5 +12,9 @@ func init() {
 	EchoService.SetupRoute(contract.SimpleEchoRouteContract.Label, echo, nil, nil)
 	EchoService.SetupRoute(contract.PostEchoRouteContract.Label, randomString, nil, nil)
 	EchoService.SubscribeToService(contract.RandomStringsServiceContract)
-	go EchoService.Start()
+	go func() {
+		if err := EchoService.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 }
@@ -0,0 +1,156 @@
+// Code generated by lotus gen. DO NOT EDIT.
+
+package generated
+
+import (
+	"context"
+	"github.com/brunvieira/lotus"
+	"time"
+)
+
+type GetItemData struct {
+	SKU      string `lotus:"path=sku"`
+	Detailed bool
+}
+
+type Category struct {
+	Name   string
+	Parent *Category
+}
+
+type ItemDimensions struct {
+	Height float64
+	Width  float64
+}
+
+type Item struct {
+	Sku        string   `json:"sku" msgpack:"sku" validate:"required,regex=^[A-Z0-9]+$"`
+	Name       string   `validate:"required,min=2,max=40"`
+	Quantity   int      `validate:"min=0"`
+	Tags       []string `validate:"max=5"`
+	Size       string   `validate:"omitempty,enum=S|M|L"`
+	Dimensions *ItemDimensions
+	Category   Category
+	ArrivedAt  time.Time
+}
+
+// HealthRouteContract is the contract of the Health route. Reports whether the service is up
+var HealthRouteContract = lotus.RouteContract{
+	Label:       "Health",
+	Description: "Reports whether the service is up",
+	Method:      lotus.GET,
+	Path:        "/health",
+}
+
+// GetItemRouteContract is the contract of the GetItem route. Returns an item of a warehouse
+var GetItemRouteContract = lotus.RouteContract{
+	Label:       "GetItem",
+	Description: "Returns an item of a warehouse",
+	Method:      lotus.GET,
+	Path:        "/warehouses/:warehouse/items/:sku",
+	Retry: &lotus.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+	},
+	Timeout: 2 * time.Second,
+	Data:    GetItemData{},
+}
+
+// AddItemRouteContract is the contract of the AddItem route. Adds an item to the inventory
+var AddItemRouteContract = lotus.RouteContract{
+	Label:       "AddItem",
+	Description: "Adds an item to the inventory",
+	Method:      lotus.POST,
+	Path:        "/items",
+	DataHandlerConfig: lotus.DataHandlerConfig{
+		BodyType: lotus.JSON,
+	},
+	Data: Item{},
+}
+
+// InventoryContract is the contract of the Inventory service. Keeps track of the items in stock
+var InventoryContract = lotus.ServiceContract{
+	Label:       "Inventory",
+	Description: "Keeps track of the items in stock",
+	Host:        "localhost",
+	Namespace:   "inventory",
+	Port:        9082,
+	Version:     "v1",
+	RoutesContracts: []lotus.RouteContract{
+		HealthRouteContract,
+		GetItemRouteContract,
+		AddItemRouteContract,
+	},
+	Environments: map[string]lotus.ServiceOverride{
+		"docker": {Host: "inventory", Port: 80},
+	},
+	ClientOptions: &lotus.ClientOptions{
+		MaxConns:            64,
+		MaxIdleConnDuration: 30 * time.Second,
+		DialTimeout:         500 * time.Millisecond,
+	},
+}
+
+// InventoryClient is a typed client of the Inventory service, e.g.
+//
+//	client := InventoryClient{lotus.ServiceClient{ServiceContract: &InventoryContract}}
+type InventoryClient struct {
+	lotus.ServiceClient
+}
+
+// InventoryClientFromContext returns the client of the Inventory service subscribed by the service handling ctx
+func InventoryClientFromContext(ctx *lotus.Context) (*InventoryClient, bool) {
+	for _, client := range ctx.ServiceClients {
+		if client.ServiceContract != nil && client.Label == "Inventory" {
+			return &InventoryClient{client}, true
+		}
+	}
+	return nil, false
+}
+
+// Health calls the Health route, decoding the response body into out
+func (c *InventoryClient) Health(ctx context.Context, out interface{}) error {
+	return c.Call(ctx, HealthRouteContract, lotus.ServiceRequest{}, out)
+}
+
+// GetItem calls the GetItem route, decoding the response body into out
+func (c *InventoryClient) GetItem(ctx context.Context, data GetItemData, warehouse string, out interface{}) error {
+	return c.Call(ctx, GetItemRouteContract, lotus.ServiceRequest{RouteParams: map[string]string{"warehouse": warehouse}, Body: data}, out)
+}
+
+// AddItem calls the AddItem route, decoding the response body into out
+func (c *InventoryClient) AddItem(ctx context.Context, data Item, out interface{}) error {
+	return c.Call(ctx, AddItemRouteContract, lotus.ServiceRequest{Body: data}, out)
+}
+
+// InventoryServer handles the routes of the Inventory service
+type InventoryServer interface {
+	// Health reports whether the service is up
+	Health(ctx *lotus.Context)
+	// GetItem returns an item of a warehouse
+	GetItem(ctx *lotus.Context, data GetItemData)
+	// AddItem adds an item to the inventory
+	AddItem(ctx *lotus.Context, data Item)
+}
+
+// SetupInventoryServer sets up the methods of server as the routes of service, which must be created with the
+// InventoryContract. Requests with a payload that can't be decoded are answered with ErrBadRequest
+func SetupInventoryServer(service *lotus.Service, server InventoryServer) {
+	service.SetupRoute(HealthRouteContract.Label, server.Health, nil, nil)
+	service.SetupRoute(GetItemRouteContract.Label, func(ctx *lotus.Context) {
+		data, err := lotus.Payload[GetItemData](ctx)
+		if err != nil {
+			ctx.Error(lotus.ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		server.GetItem(ctx, data)
+	}, nil, nil)
+	service.SetupRoute(AddItemRouteContract.Label, func(ctx *lotus.Context) {
+		data, err := lotus.Payload[Item](ctx)
+		if err != nil {
+			ctx.Error(lotus.ErrBadRequest.WithMessage(err.Error()))
+			return
+		}
+		server.AddItem(ctx, data)
+	}, nil, nil)
+}
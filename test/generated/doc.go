@@ -0,0 +1,4 @@
+// Package generated holds the code generated by lotus gen from contract.yaml. It's used to test the generator
+package generated
+
+//go:generate go run ../../cmd/lotus gen -pkg generated -o contract.go contract.yaml
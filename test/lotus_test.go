@@ -1,18 +1,26 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"github.com/brunvieira/lotus"
 	"github.com/brunvieira/lotus/test/contract"
 	"github.com/brunvieira/lotus/test/echo_service"
+	"github.com/brunvieira/lotus/test/generated"
+	"github.com/brunvieira/lotus/test/random_strings"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
 	"log"
+	"os"
 	"testing"
-
-	_ "github.com/brunvieira/lotus/test/random_strings"
 )
 
+func TestMain(m *testing.M) {
+	<-echo_service.EchoService.Ready()
+	<-random_strings.RandomStringService.Ready()
+	os.Exit(m.Run())
+}
+
 func testRequestToHandler(
 	t *testing.T,
 	method lotus.Method,
@@ -38,7 +46,7 @@ func testRequestToHandler(
 }
 
 func TestNoMiddlewares(t *testing.T) {
-	service := echo_service.EchoService
+	service := &echo_service.EchoService
 
 	endpoint := service.Suffix() + contract.SimpleEchoRouteContract.Path
 	url, err := service.RouteUrl(contract.SimpleEchoRouteContract.Label)
@@ -57,7 +65,7 @@ func TestNoMiddlewares(t *testing.T) {
 }
 
 func TestClient(t *testing.T) {
-	service := echo_service.EchoService
+	service := &echo_service.EchoService
 
 	url, err := service.RouteUrl(contract.PostEchoRouteContract.Label)
 	assert.Nil(t, err, "Service must build a valid url for a route")
@@ -73,3 +81,73 @@ func TestClient(t *testing.T) {
 	assert.NotEmptyf(t, body, "Reading the body response should not return an error")
 	log.Printf("Body: %s", body)
 }
+
+func TestContractFile(t *testing.T) {
+	loaded, err := lotus.LoadContract("contract/contract.yaml")
+	assert.Nil(t, err, "Loading the contract file must not return an error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := lotus.DiffContracts(contract.Contract.Describe(), loaded.Describe())
+	assert.Empty(t, diff.Changes, "The contract file must match the Go contract")
+
+	docker, err := loaded.WithEnvironment("docker")
+	assert.Nil(t, err, "Applying the docker environment must not return an error")
+	url, _ := docker.Services[0].RouteUrl(contract.SimpleEchoRouteContract.Label)
+	assert.Equal(t, "http://echo:80/echo_service/v0/echo", url, "The environment address must be used")
+}
+
+type inventoryServer struct{}
+
+func (inventoryServer) Health(ctx *lotus.Context) {
+	ctx.WriteString("ok")
+}
+
+func (inventoryServer) GetItem(ctx *lotus.Context, data generated.GetItemData) {
+	ctx.WriteString(fmt.Sprintf("%s/%s/%t", ctx.UserValue("warehouse"), data.SKU, data.Detailed))
+}
+
+func (inventoryServer) AddItem(ctx *lotus.Context, data generated.Item) {
+	ctx.WritePayload(data)
+}
+
+func TestGeneratedCode(t *testing.T) {
+	service := lotus.Service{ServiceContract: &generated.InventoryContract}
+	generated.SetupInventoryServer(&service, inventoryServer{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- service.Start()
+	}()
+	select {
+	case err := <-errc:
+		t.Fatal(err)
+	case <-service.Ready():
+	}
+	defer service.Stop()
+
+	client := generated.InventoryClient{ServiceClient: lotus.ServiceClient{ServiceContract: &generated.InventoryContract}}
+	var health string
+	err := client.Health(context.Background(), &health)
+	assert.Nil(t, err, "Calling a route without data must not return an error")
+	assert.Equal(t, "ok", health)
+
+	var item string
+	err = client.GetItem(context.Background(), generated.GetItemData{SKU: "A1", Detailed: true}, "north", &item)
+	assert.Nil(t, err, "Calling a route with path params must not return an error")
+	assert.Equal(t, "north/A1/true", item, "Bound and unbound path params must be sent")
+
+	added := generated.Item{
+		Sku:      "A1",
+		Name:     "Lotus",
+		Quantity: 3,
+		Category: generated.Category{Name: "Flowers", Parent: &generated.Category{Name: "Plants"}},
+	}
+	var echoed generated.Item
+	err = client.AddItem(context.Background(), added, &echoed)
+	assert.Nil(t, err, "Calling a route with a body must not return an error")
+	assert.Equal(t, "Plants", echoed.Category.Parent.Name, "The payload must reach the server typed")
+
+	err = client.AddItem(context.Background(), generated.Item{Sku: "a1", Name: "Lotus"}, nil)
+	assert.NotNil(t, err, "Generated validation rules must be enforced")
+}
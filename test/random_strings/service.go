@@ -3,6 +3,7 @@ package random_strings
 import (
 	"github.com/brunvieira/lotus"
 	"github.com/brunvieira/lotus/test/contract"
+	"log"
 )
 
 var (
@@ -13,5 +14,9 @@ var (
 
 func init() {
 	RandomStringService.SetupRoute(contract.RandomStringsRouteContract.Label, generateRandomStrings, nil, nil)
-	go RandomStringService.Start()
+	go func() {
+		if err := RandomStringService.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 }
@@ -0,0 +1,133 @@
+package lotus
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader carries the remaining time budget of a request, in milliseconds. ServiceClient sets it from the
+// deadline of the call and services use it as the deadline of the Context handling the request
+const TimeoutHeader = "Lotus-Timeout"
+
+// Deadline returns the time the request must be answered by. It's bounded by the TimeoutHeader received and the route
+// Timeout. Pass the Context to ServiceClient calls to propagate the remaining budget
+func (ctx *Context) Deadline() (time.Time, bool) {
+	if ctx.ctx == nil {
+		return ctx.RequestCtx.Deadline()
+	}
+	return ctx.ctx.Deadline()
+}
+
+// Done is closed when the request deadline expires or the service shuts down
+func (ctx *Context) Done() <-chan struct{} {
+	if ctx.ctx == nil {
+		return ctx.RequestCtx.Done()
+	}
+	return ctx.ctx.Done()
+}
+
+// Err returns context.DeadlineExceeded once the request deadline expires and context.Canceled once the service
+// shuts down
+func (ctx *Context) Err() error {
+	if ctx.ctx == nil {
+		return ctx.RequestCtx.Err()
+	}
+	return ctx.ctx.Err()
+}
+
+// Value returns the request user value of key
+func (ctx *Context) Value(key interface{}) interface{} {
+	if ctx.ctx == nil {
+		return ctx.RequestCtx.Value(key)
+	}
+	return ctx.ctx.Value(key)
+}
+
+// serverContext is a RequestCtx with the Done channel of its server taken while the request is served. The server
+// resets its channel on Shutdown, racing with the goroutine context.WithDeadline starts to watch its parent
+type serverContext struct {
+	*fasthttp.RequestCtx
+	done <-chan struct{}
+}
+
+func (ctx serverContext) Done() <-chan struct{} {
+	return ctx.done
+}
+
+func (ctx serverContext) Err() error {
+	select {
+	case <-ctx.done:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+// requestDeadline returns the deadline of a request received at start, from its TimeoutHeader and the route timeout
+func requestDeadline(req *fasthttp.Request, start time.Time, timeout time.Duration) (time.Time, bool) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = start.Add(timeout)
+	}
+	if header := req.Header.Peek(TimeoutHeader); len(header) > 0 {
+		if ms, err := strconv.ParseInt(string(header), 10, 64); err == nil {
+			budget := start.Add(time.Duration(ms) * time.Millisecond)
+			if deadline.IsZero() || budget.Before(deadline) {
+				deadline = budget
+			}
+		}
+	}
+	return deadline, !deadline.IsZero()
+}
+
+// withTimeout bounds ctx with the timeout of the call: the ServiceRequest Timeout, the route Timeout or the client
+// Timeout, in that order
+func (sc *ServiceClient) withTimeout(ctx context.Context, routeContract RouteContract, payload ServiceRequest) (context.Context, context.CancelFunc) {
+	timeout := payload.Timeout
+	if timeout <= 0 {
+		timeout = routeContract.Timeout
+	}
+	if timeout <= 0 {
+		timeout = sc.Timeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// callerGaveUp reports whether a failed call was cut by its caller, canceling caller or through its deadline or the
+// ServiceRequest Timeout, rather than by a slow instance. Route and client Timeouts are failures of the instance
+func callerGaveUp(caller context.Context, payload ServiceRequest, err error) bool {
+	if err == nil {
+		return false
+	}
+	if caller.Err() != nil {
+		return true
+	}
+	if err != fasthttp.ErrTimeout && err != context.DeadlineExceeded {
+		return false
+	}
+	if deadline, ok := caller.Deadline(); ok && !time.Now().Before(deadline) {
+		// the deadline of the request may expire before the one of caller is reported
+		return true
+	}
+	return payload.Timeout > 0
+}
+
+// setTimeoutHeader writes the remaining budget until deadline on the request. Budgets are rounded up so a request
+// is never sent with a zero budget before its deadline
+func setTimeoutHeader(req *fasthttp.Request, deadline time.Time) {
+	if deadline.IsZero() {
+		req.Header.Del(TimeoutHeader)
+		return
+	}
+	remaining := time.Until(deadline)
+	ms := (remaining + time.Millisecond - 1) / time.Millisecond
+	if ms < 0 {
+		ms = 0
+	}
+	req.Header.Set(TimeoutHeader, strconv.FormatInt(int64(ms), 10))
+}
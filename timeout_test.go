@@ -0,0 +1,149 @@
+package lotus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var (
+	WaitRouteContract = RouteContract{
+		Label: "Wait",
+		Path:  "/wait",
+	}
+	BudgetRouteContract = RouteContract{
+		Label: "Budget",
+		Path:  "/budget",
+	}
+	StallRouteContract = RouteContract{
+		Label: "Stall",
+		Path:  "/stall",
+	}
+	ProxyRouteContract = RouteContract{
+		Label:   "Proxy",
+		Path:    "/proxy",
+		Timeout: 50 * time.Millisecond,
+	}
+	DeadlineServiceContract = ServiceContract{
+		Label:           "Deadline",
+		Port:            10103,
+		RoutesContracts: []RouteContract{WaitRouteContract, BudgetRouteContract, StallRouteContract, ProxyRouteContract},
+	}
+)
+
+func TestTimeouts(t *testing.T) {
+	service := Service{ServiceContract: &DeadlineServiceContract}
+	service.SubscribeToService(DeadlineServiceContract)
+	service.SetupRoute("Wait", func(ctx *Context) {
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		ctx.WriteString("waited")
+	}, nil, nil)
+	service.SetupRoute("Budget", func(ctx *Context) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			ctx.WriteString("none")
+			return
+		}
+		ctx.WriteString(strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}, nil, nil)
+	service.SetupRoute("Stall", func(ctx *Context) {
+		time.Sleep(200 * time.Millisecond)
+	}, nil, nil)
+	service.SetupRoute("Proxy", func(ctx *Context) {
+		err := ctx.ServiceClient(DeadlineServiceContract).Call(ctx, WaitRouteContract, ServiceRequest{}, nil)
+		ctx.WriteString(strconv.FormatBool(errors.Is(err, context.DeadlineExceeded)))
+	}, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	client := ServiceClient{ServiceContract: &DeadlineServiceContract}
+	start := time.Now()
+	err := client.Call(context.Background(), WaitRouteContract, ServiceRequest{Timeout: 20 * time.Millisecond}, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Calls must stop once their timeout elapses")
+	assert.Less(t, int64(time.Since(start)), int64(300*time.Millisecond))
+
+	route := WaitRouteContract
+	route.Timeout = 20 * time.Millisecond
+	resp, err := client.SendRequestContext(context.Background(), route, ServiceRequest{})
+	fasthttp.ReleaseResponse(resp)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "The route Timeout must bound calls")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.Call(ctx, WaitRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, context.Canceled), "Canceled calls must not be sent")
+
+	var budget string
+	err = client.Call(context.Background(), BudgetRouteContract, ServiceRequest{}, &budget)
+	assert.Nil(t, err)
+	assert.Equal(t, "none", budget, "Requests without timeout must not have a deadline")
+
+	client.Timeout = time.Second
+	err = client.Call(context.Background(), BudgetRouteContract, ServiceRequest{}, &budget)
+	assert.Nil(t, err)
+	remaining, _ := strconv.Atoi(budget)
+	assert.True(t, remaining > 500 && remaining <= 1000, "The remaining budget must be propagated, got %s", budget)
+
+	var deadlineExceeded string
+	start = time.Now()
+	err = client.Call(context.Background(), ProxyRouteContract, ServiceRequest{Timeout: time.Second}, &deadlineExceeded)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", deadlineExceeded, "Downstream calls must stop when the inbound deadline expires")
+	assert.Less(t, int64(time.Since(start)), int64(300*time.Millisecond))
+
+	url, _ := DeadlineServiceContract.RouteUrl("Budget")
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp = fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(url)
+	req.Header.Set(TimeoutHeader, "0")
+	fasthttp.Do(req, resp)
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, resp.StatusCode(), "Requests received past their deadline must not be handled")
+
+	breaker := &CircuitBreaker{MinRequests: 1, CoolDown: time.Minute}
+	impatient := ServiceClient{ServiceContract: &DeadlineServiceContract, CircuitBreaker: breaker}
+	impatientCtx, cancelImpatient := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelImpatient()
+	err = impatient.Call(impatientCtx, StallRouteContract, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	err = impatient.Call(context.Background(), StallRouteContract, ServiceRequest{Timeout: 20 * time.Millisecond}, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, CircuitClosed, breaker.Status()[0].State, "Deadlines set by the caller must not count as circuit failures")
+
+	stall := StallRouteContract
+	stall.Timeout = 20 * time.Millisecond
+	err = impatient.Call(context.Background(), stall, ServiceRequest{}, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, CircuitOpen, breaker.Status()[0].State, "Route timeouts must count as circuit failures")
+}
+
+func TestRequestDeadline(t *testing.T) {
+	start := time.Now()
+	req := &fasthttp.Request{}
+	_, ok := requestDeadline(req, start, 0)
+	assert.False(t, ok, "Requests without budget nor route timeout must not have a deadline")
+
+	deadline, ok := requestDeadline(req, start, time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Second), deadline, "The route timeout must bound the request")
+
+	req.Header.Set(TimeoutHeader, "250")
+	deadline, _ = requestDeadline(req, start, time.Second)
+	assert.Equal(t, start.Add(250*time.Millisecond), deadline, "The shortest budget must be used")
+	deadline, _ = requestDeadline(req, start, 0)
+	assert.Equal(t, start.Add(250*time.Millisecond), deadline)
+
+	setTimeoutHeader(req, time.Now().Add(1500*time.Millisecond))
+	assert.Equal(t, "1500", string(req.Header.Peek(TimeoutHeader)), "The remaining budget must be sent in milliseconds")
+	setTimeoutHeader(req, time.Time{})
+	assert.Empty(t, req.Header.Peek(TimeoutHeader), "Calls without deadline must not send a budget")
+
+}
@@ -0,0 +1,71 @@
+package lotus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"os"
+	"sync"
+)
+
+// tlsClients caches a fasthttp.Client per client TLS configuration so connections are reused across calls
+var tlsClients sync.Map
+
+// NewTLSConfig creates a tls.Config from PEM files that can be used both to serve and to call HTTPS services.
+// certFile and keyFile hold the certificate presented to the other side. When caFile is given, its certificates
+// are used to verify the server certificate on clients and to require and verify client certificates on services,
+// enabling mutual TLS. Any of the files can be empty
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found on %s", caFile)
+		}
+		config.RootCAs = pool
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// serverTLSConfig returns the configuration used to serve HTTPS, loading CertFile and KeyFile if set
+func (service *Service) serverTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if service.TLSConfig != nil {
+		config = service.TLSConfig.Clone()
+	}
+	if service.CertFile != "" || service.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(service.CertFile, service.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		return nil, errors.New("service " + service.Label + " uses HTTPS but has no certificate")
+	}
+	return config, nil
+}
+
+// tlsClient returns the fasthttp.Client used to call services with the given TLS configuration
+func tlsClient(config *tls.Config) *fasthttp.Client {
+	if client, ok := tlsClients.Load(config); ok {
+		return client.(*fasthttp.Client)
+	}
+	client, _ := tlsClients.LoadOrStore(config, &fasthttp.Client{TLSConfig: config})
+	return client.(*fasthttp.Client)
+}
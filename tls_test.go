@@ -0,0 +1,116 @@
+package lotus
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	SecureRouteContract = RouteContract{
+		Label: "Secure",
+		Path:  "/secure",
+	}
+	SecureServiceContract = ServiceContract{
+		Label:           "SecureService",
+		Protocol:        HTTPS,
+		Port:            10091,
+		RoutesContracts: []RouteContract{SecureRouteContract},
+	}
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// writeCertificate creates a certificate signed by parent (or self signed when parent is nil) and writes its PEM
+// files on dir, returning the certificate and the file paths
+func writeCertificate(t *testing.T, dir, name string, parent *testCertificate, isCA bool) (*testCertificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return &testCertificate{cert, key}, certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caFile, _ := writeCertificate(t, dir, "ca", nil, true)
+	_, serverCert, serverKey := writeCertificate(t, dir, "server", ca, false)
+	_, clientCert, clientKey := writeCertificate(t, dir, "client", ca, false)
+
+	withoutCertificate := Service{ServiceContract: &SecureServiceContract}
+	withoutCertificate.SetupRoute("Secure", echo, nil, nil)
+	assert.NotNil(t, withoutCertificate.Start(), "Starting an HTTPS service without certificate must return an error")
+
+	serverConfig, err := NewTLSConfig("", "", caFile)
+	assert.Nil(t, err, "Creating the server TLS config must not return an error")
+
+	service := Service{
+		ServiceContract: &SecureServiceContract,
+		TLSConfig:       serverConfig,
+		CertFile:        serverCert,
+		KeyFile:         serverKey,
+	}
+	service.SetupRoute("Secure", echo, nil, nil)
+	startService(t, &service)
+	defer service.Stop()
+
+	clientConfig, err := NewTLSConfig(clientCert, clientKey, caFile)
+	assert.Nil(t, err, "Creating the client TLS config must not return an error")
+
+	client := ServiceClient{ServiceContract: &SecureServiceContract, TLSConfig: clientConfig}
+	var body string
+	err = client.Call(context.Background(), SecureRouteContract, ServiceRequest{}, &body)
+	assert.Nil(t, err, "Calling an HTTPS service with a client certificate must not return an error")
+	assert.Equal(t, "GET:/v0/secure", body, "The HTTPS service must answer the request")
+
+	anonymousConfig, _ := NewTLSConfig("", "", caFile)
+	anonymous := ServiceClient{ServiceContract: &SecureServiceContract, TLSConfig: anonymousConfig}
+	err = anonymous.Call(context.Background(), SecureRouteContract, ServiceRequest{}, &body)
+	assert.NotNil(t, err, "Calling a mutual TLS service without a client certificate must return an error")
+
+	plain := ServiceClient{ServiceContract: &SecureServiceContract}
+	err = plain.Call(context.Background(), SecureRouteContract, ServiceRequest{}, &body)
+	assert.NotNil(t, err, "Calling an HTTPS service without trusting its CA must return an error")
+}
@@ -0,0 +1,334 @@
+package lotus
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validateTagName is the struct tag holding the validation rules of a Data field. Rules are comma separated and
+// parameters are given after an equal sign, e.g. `validate:"required,min=1,max=10"`. The available rules are:
+//
+//	required   the field must not hold its zero value
+//	omitempty  the other rules are skipped when the field holds its zero value
+//	min=n      numbers must be >= n, strings, slices and maps must have at least n elements
+//	max=n      numbers must be <= n, strings, slices and maps must have at most n elements
+//	len=n      strings, slices and maps must have exactly n elements
+//	regex=re   strings must match the regular expression re. It must be the last rule, so re may contain commas
+//	enum=a|b   the field value must be one of the listed values
+//
+// Rules apply to zero values unless omitempty is given, e.g. `validate:"min=1"` rejects 0. Rules of nil pointers are
+// skipped, so pointers declare optional fields. Nested structs, pointers to structs and slices of structs are
+// validated recursively
+const validateTagName = "validate"
+
+// FieldError describes a validation rule a Data field failed
+type FieldError struct {
+	// Field is the path of the field on the Data, e.g. "Address.Street" or "Items[0].Name"
+	Field string
+	// Rule is the name of the failing rule
+	Rule string
+	// Param is the parameter of the failing rule, if any
+	Param string
+	// Message is a human readable description of the failure
+	Message string
+}
+
+// ValidationError lists every Data field that failed validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+type validationRule struct {
+	name  string
+	param string
+	n     float64
+	re    *regexp.Regexp
+	enum  []string
+}
+
+type fieldValidation struct {
+	index     int
+	name      string
+	omitEmpty bool
+	rules     []validationRule
+}
+
+type typeValidation struct {
+	validated bool
+	err       error
+}
+
+type fieldValidations struct {
+	fields []fieldValidation
+	err    error
+}
+
+var (
+	// validations caches the fieldValidations of each validated type
+	validations sync.Map
+	// validatedTypes caches whether a type declares validation rules
+	validatedTypes sync.Map
+)
+
+// Validate checks data against the validation rules declared on its fields. It returns a *ValidationError
+// listing every failing field, an error when the rules are malformed or nil when data is valid
+func Validate(data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	validation := validationOf(reflect.TypeOf(data))
+	if validation.err != nil {
+		return validation.err
+	}
+	if !validation.validated {
+		return nil
+	}
+	var fields []FieldError
+	validateValue(reflect.ValueOf(data), "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// hasValidation reports whether typ, or any type nested on it, declares validation rules. Types with malformed rules
+// are reported as validated, so Validate returns the error
+func hasValidation(typ reflect.Type) bool {
+	validation := validationOf(typ)
+	return validation.validated || validation.err != nil
+}
+
+// validationRulesError returns an error when the validation rules declared by the type of data, or any type nested
+// on it, are malformed
+func validationRulesError(data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	return validationOf(reflect.TypeOf(data)).err
+}
+
+func validationOf(typ reflect.Type) typeValidation {
+	if typ == nil {
+		return typeValidation{}
+	}
+	if cached, ok := validatedTypes.Load(typ); ok {
+		return cached.(typeValidation)
+	}
+	var result typeValidation
+	result.validated, result.err = typeHasValidation(typ, map[reflect.Type]bool{})
+	validatedTypes.Store(typ, result)
+	return result
+}
+
+func typeHasValidation(typ reflect.Type, seen map[reflect.Type]bool) (validated bool, err error) {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType || seen[typ] {
+		return false, nil
+	}
+	seen[typ] = true
+	validations := validationsOf(typ)
+	if validations.err != nil {
+		return true, validations.err
+	}
+	for _, f := range validations.fields {
+		nested, err := typeHasValidation(typ.Field(f.index).Type, seen)
+		if err != nil {
+			return true, err
+		}
+		validated = validated || nested || len(f.rules) > 0
+	}
+	return validated, nil
+}
+
+func validateValue(v reflect.Value, path string, fields *[]FieldError) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			validateValue(v.Elem(), path, fields)
+		}
+	case reflect.Slice, reflect.Array:
+		if !hasValidation(v.Type()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	case reflect.Struct:
+		if !hasValidation(v.Type()) {
+			return
+		}
+		for _, f := range validationsOf(v.Type()).fields {
+			fieldPath := f.name
+			if path != "" {
+				fieldPath = path + "." + f.name
+			}
+			field := v.Field(f.index)
+			if f.omitEmpty && field.IsZero() {
+				continue
+			}
+			for _, rule := range f.rules {
+				if message := rule.check(field); message != "" {
+					*fields = append(*fields, FieldError{
+						Field:   fieldPath,
+						Rule:    rule.name,
+						Param:   rule.param,
+						Message: message,
+					})
+				}
+			}
+			validateValue(field, fieldPath, fields)
+		}
+	}
+}
+
+func validationsOf(typ reflect.Type) fieldValidations {
+	if cached, ok := validations.Load(typ); ok {
+		return cached.(fieldValidations)
+	}
+	var result fieldValidations
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		rules, omitEmpty, err := parseValidationRules(field.Tag.Get(validateTagName))
+		if err != nil {
+			result = fieldValidations{err: fmt.Errorf("invalid validation rules of %s.%s: %w", typ.Name(), field.Name, err)}
+			break
+		}
+		result.fields = append(result.fields, fieldValidation{
+			index:     i,
+			name:      field.Name,
+			omitEmpty: omitEmpty,
+			rules:     rules,
+		})
+	}
+	validations.Store(typ, result)
+	return result
+}
+
+// parseValidationRules parses the rules of a validate tag. The regex rule ends the tag, so its expression may
+// contain commas
+func parseValidationRules(tag string) (rules []validationRule, omitEmpty bool, err error) {
+	for tag != "" {
+		var option string
+		if strings.HasPrefix(strings.TrimSpace(tag), "regex=") {
+			option, tag = tag, ""
+		} else {
+			option, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(option), "=")
+		rule := validationRule{name: name, param: param}
+		switch name {
+		case "min", "max", "len":
+			rule.n, err = strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid %s parameter %q", name, param)
+			}
+		case "regex":
+			rule.re, err = regexp.Compile(param)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid regex parameter: %w", err)
+			}
+		case "enum":
+			rule.enum = strings.Split(param, "|")
+		case "omitempty":
+			omitEmpty = true
+			continue
+		case "required":
+		default:
+			return nil, false, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, omitEmpty, nil
+}
+
+// check returns a failure message or an empty string when v satisfies the rule
+func (rule validationRule) check(v reflect.Value) string {
+	if rule.name == "required" {
+		if v.IsZero() || (hasLength(v) && v.Len() == 0) {
+			return "is required"
+		}
+		return ""
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch rule.name {
+	case "min", "max", "len":
+		size, isNumber, ok := measure(v)
+		if !ok {
+			return ""
+		}
+		unit := ""
+		if !isNumber {
+			unit = " in length"
+		}
+		switch {
+		case rule.name == "min" && size < rule.n:
+			return fmt.Sprintf("must be at least %s%s", rule.param, unit)
+		case rule.name == "max" && size > rule.n:
+			return fmt.Sprintf("must be at most %s%s", rule.param, unit)
+		case rule.name == "len" && size != rule.n:
+			return fmt.Sprintf("must be exactly %s%s", rule.param, unit)
+		}
+	case "regex":
+		if v.Kind() == reflect.String && !rule.re.MatchString(v.String()) {
+			return fmt.Sprintf("must match %s", rule.param)
+		}
+	case "enum":
+		value := fmt.Sprint(v.Interface())
+		for _, e := range rule.enum {
+			if e == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(rule.enum, ", "))
+	}
+	return ""
+}
+
+func hasLength(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// measure returns the value of numbers or the length of strings, slices and maps
+func measure(v reflect.Value) (size float64, isNumber bool, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, true
+	case reflect.String:
+		return float64(len([]rune(v.String()))), false, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), false, true
+	}
+	return 0, false, false
+}
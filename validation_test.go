@@ -0,0 +1,178 @@
+package lotus
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/buaazp/fasthttprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"net"
+	"testing"
+)
+
+type Address struct {
+	Street string `validate:"required"`
+	Zip    string `validate:"omitempty,len=5,regex=^[0-9]+$"`
+}
+
+type SignUp struct {
+	Name      string   `validate:"required,min=2,max=10"`
+	Age       int      `validate:"min=18,max=130"`
+	Plan      string   `validate:"omitempty,enum=free|pro"`
+	Tags      []string `validate:"max=2"`
+	Address   Address
+	Secondary *Address
+	Contacts  []Address
+}
+
+var validSignUp = SignUp{
+	Name:     "lotus",
+	Age:      30,
+	Plan:     "pro",
+	Tags:     []string{"foo"},
+	Address:  Address{Street: "Main St", Zip: "12345"},
+	Contacts: []Address{{Street: "Second St", Zip: "54321"}},
+}
+
+func fieldNames(err error) []string {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+	names := make([]string, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		names[i] = f.Field + ":" + f.Rule
+	}
+	return names
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate(validSignUp), "A valid payload must not return an error")
+	assert.Nil(t, Validate(&validSignUp), "A valid payload pointer must not return an error")
+	assert.Nil(t, Validate(EchoPayload{}), "A payload without rules must not return an error")
+
+	invalid := SignUp{
+		Name:      "l",
+		Age:       12,
+		Plan:      "enterprise",
+		Tags:      []string{"foo", "bar", "baz"},
+		Address:   Address{Zip: "1234a"},
+		Secondary: &Address{Street: "Main St", Zip: "123"},
+		Contacts:  []Address{{Zip: "12345"}},
+	}
+	err := Validate(invalid)
+	assert.NotNil(t, err, "An invalid payload must return an error")
+	assert.Equal(t, []string{
+		"Name:min",
+		"Age:min",
+		"Plan:enum",
+		"Tags:max",
+		"Address.Street:required",
+		"Address.Zip:regex",
+		"Secondary.Zip:len",
+		"Contacts[0].Street:required",
+	}, fieldNames(err), "Every failing field must be reported")
+}
+
+func TestValidateZeroValues(t *testing.T) {
+	type Order struct {
+		Quantity int     `validate:"min=1,max=10"`
+		Coupon   string  `validate:"omitempty,len=8"`
+		Discount *int    `validate:"max=50"`
+		Code     string  `validate:"regex=^[A-Z]{2,3}$"`
+		Notes    *string `validate:"omitempty,min=1"`
+	}
+	assert.Equal(t, []string{"Quantity:min", "Code:regex"}, fieldNames(Validate(Order{})),
+		"Rules must apply to zero values unless omitempty is given or the field is a nil pointer")
+	assert.Nil(t, Validate(Order{Quantity: 1, Code: "LTS"}), "Rules with commas must be parsed as the last rule")
+	assert.Equal(t, []string{"Coupon:len"}, fieldNames(Validate(Order{Quantity: 1, Code: "LTS", Coupon: "x"})),
+		"Rules must apply to non zero values of omitempty fields")
+	assert.Equal(t, []string{"Quantity:min"}, fieldNames(Validate(struct {
+		Quantity int `validate:"min=1"`
+	}{})), "Numbers must be checked against their bounds when zero")
+}
+
+func TestMalformedValidationRules(t *testing.T) {
+	type UnknownRule struct {
+		Name string `validate:"requird"`
+	}
+	type InvalidBound struct {
+		Age int `validate:"min=eighteen"`
+	}
+	type InvalidRegex struct {
+		Code string `validate:"regex=^[A-Z"`
+	}
+	type Nested struct {
+		Rules []InvalidBound
+	}
+	for _, data := range []interface{}{UnknownRule{}, InvalidBound{}, InvalidRegex{}, Nested{}} {
+		err := Validate(data)
+		assert.NotNil(t, err, "Malformed rules must return an error instead of panicking")
+		assert.Nil(t, fieldNames(err), "Malformed rules must not be reported as failing fields")
+	}
+	assert.Contains(t, Validate(Nested{}).Error(), "InvalidBound.Age")
+
+	contract := RouteContract{Label: "Malformed", Path: "/malformed", Data: UnknownRule{}}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	assert.NotNil(t, contract.prepareRequest(req, ServiceRequest{Body: UnknownRule{}}), "Clients must return malformed rules errors")
+
+	service := Service{ServiceContract: &ServiceContract{Label: "Malformed", RoutesContracts: []RouteContract{contract}}}
+	service.SetupRoute("Malformed", echo, nil, nil)
+	err := service.Start()
+	assert.NotNil(t, err, "Starting a service with malformed rules must return an error")
+	assert.Contains(t, err.Error(), "unknown rule")
+}
+
+func TestValidationOnDataHandler(t *testing.T) {
+	path := "/signup"
+	contract := &RouteContract{
+		Label:             "SignUp",
+		Method:            POST,
+		Path:              path,
+		DataHandlerConfig: DataHandlerConfig{BodyType: JSON},
+		Data:              SignUp{},
+	}
+	route := &Route{RouteContract: contract, RequestHandler: func(ctx *Context) {
+		ctx.WriteString("signed up")
+	}}
+
+	router := fasthttprouter.New()
+	route.startRoute(router, "")
+
+	url := "localhost:10086"
+	ln, _ := net.Listen("tcp", url)
+	go fasthttp.Serve(ln, router.Handler)
+	defer ln.Close()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://" + url + path)
+	err := contract.prepareRequest(req, ServiceRequest{Body: SignUp{Name: "l"}})
+	assert.NotNil(t, err, "Preparing an invalid request must return an error")
+	assert.Contains(t, fieldNames(err), "Name:min", "Client side validation must report failing fields")
+
+	err = contract.prepareRequest(req, ServiceRequest{Body: validSignUp})
+	assert.Nil(t, err, "Preparing a valid request must not return an error")
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, "signed up", string(resp.Body()), "A valid payload must reach the handler")
+
+	req.SetBodyString(`{"Name": "l", "Age": 30}`)
+	err = fasthttp.Do(req, resp)
+	assert.Nil(t, err, "Sending the request must not return an error")
+	assert.Equal(t, fasthttp.StatusUnprocessableEntity, resp.StatusCode(), "An invalid payload must return a 422 status")
+
+	var lotusErr Error
+	err = json.Unmarshal(resp.Body(), &lotusErr)
+	assert.Nil(t, err, "The validation error must be encoded with the route DataType")
+	assert.Equal(t, CodeValidationFailed, lotusErr.Code)
+	var validationErr ValidationError
+	err = lotusErr.DecodeDetails(&validationErr.Fields)
+	assert.Nil(t, err, "The failing fields must be the error details")
+	assert.Equal(t, []string{"Name:min", "Address.Street:required"}, fieldNames(&validationErr), "Every failing field must be reported")
+}